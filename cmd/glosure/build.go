@@ -0,0 +1,63 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+  "errors"
+  "flag"
+  "fmt"
+  "io"
+
+  "github.com/soheilhy/glosure"
+)
+
+var buildCommand = &command{
+  name: "build",
+  usage: "build [-out dir] targets...\n" +
+         "      Compiles the targets (e.g., app.min.js). Use -index to " +
+         "share the index\n      of the sources with the server.",
+  run: runBuild,
+}
+
+func runBuild(args []string, stdout io.Writer) error {
+  fs := flag.NewFlagSet("build", flag.ContinueOnError)
+  sources := addSourceFlags(fs)
+  out := fs.String("out", "",
+                   "directory of the compiled outputs. Uses -root if empty.")
+  if err := fs.Parse(args); err != nil {
+    return err
+  }
+
+  if fs.NArg() == 0 {
+    return errors.New("No targets.")
+  }
+
+  cc, err := sources.compiler()
+  if err != nil {
+    return err
+  }
+
+  if *out != "" {
+    cc.Outputs = glosure.NewDirStore(*out)
+  }
+
+  for _, target := range fs.Args() {
+    if err := cc.Compile(target); err != nil {
+      return err
+    }
+    fmt.Fprintln(stdout, "Compiled " + target)
+  }
+  return nil
+}
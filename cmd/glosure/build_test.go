@@ -0,0 +1,42 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+  "bytes"
+  "os"
+  "path/filepath"
+  "testing"
+)
+
+func TestBuildWithoutTargets(t *testing.T) {
+  var out bytes.Buffer
+  if err := runBuild([]string{"-root", testRoot}, &out); err == nil {
+    t.Error("Build without targets succeeds.")
+  }
+}
+
+func TestIndexFlag(t *testing.T) {
+  index := filepath.Join(t.TempDir(), "index.json")
+  var out bytes.Buffer
+  err := runRoots([]string{"-root", testRoot, "-index", index}, &out)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  if _, err := os.Stat(index); err != nil {
+    t.Error("Index is not written: ", err)
+  }
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+  "errors"
+  "flag"
+  "fmt"
+  "io"
+  "strings"
+)
+
+var conflictsCommand = &command{
+  name: "conflicts",
+  usage: "conflicts\n" +
+         "      Lists the namespaces provided by more than one file. Fails " +
+         "if there is any.",
+  run: runConflicts,
+}
+
+func runConflicts(args []string, stdout io.Writer) error {
+  fs := flag.NewFlagSet("conflicts", flag.ContinueOnError)
+  sources := addSourceFlags(fs)
+  if err := fs.Parse(args); err != nil {
+    return err
+  }
+
+  cc, err := sources.compiler()
+  if err != nil {
+    return err
+  }
+
+  g := cc.DependencyGraph()
+  conflicts := g.Conflicts()
+  for _, c := range conflicts {
+    fmt.Fprintf(stdout, "%s: %s\n", c.Pkg, strings.Join(c.Paths, ", "))
+  }
+
+  if len(conflicts) != 0 {
+    return errors.New(fmt.Sprintf("%d conflicting namespaces.",
+                                  len(conflicts)))
+  }
+  return nil
+}
@@ -0,0 +1,39 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+  "bytes"
+  "io/ioutil"
+  "path/filepath"
+  "testing"
+)
+
+func TestConflicts(t *testing.T) {
+  var out bytes.Buffer
+  if err := runConflicts([]string{"-root", testRoot}, &out); err != nil {
+    t.Error("Conflicts without duplicate provides: ", err, out.String())
+  }
+
+  dir := t.TempDir()
+  ioutil.WriteFile(filepath.Join(dir, "dup.js"),
+                   []byte("goog.provide('pkg2');"), 0644)
+
+  out.Reset()
+  err := runConflicts([]string{"-root", testRoot, "-roots", "x/=" + dir}, &out)
+  if err == nil || out.String() != "pkg2: pkg2.js, x/dup.js\n" {
+    t.Error("Invalid conflicts: ", out.String(), err)
+  }
+}
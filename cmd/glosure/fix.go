@@ -0,0 +1,65 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+  "flag"
+  "fmt"
+  "io"
+)
+
+var fixCommand = &command{
+  name: "fix",
+  usage: "fix [-diff] [files...]\n" +
+         "      Adds missing requires, removes unused ones and sorts them in " +
+         "the files,\n      or in all files in -root.",
+  run: runFix,
+}
+
+func runFix(args []string, stdout io.Writer) error {
+  fs := flag.NewFlagSet("fix", flag.ContinueOnError)
+  sources := addSourceFlags(fs)
+  diff := fs.Bool("diff", false,
+                  "print the changes as a diff without writing the files.")
+  if err := fs.Parse(args); err != nil {
+    return err
+  }
+
+  cc, err := sources.compiler()
+  if err != nil {
+    return err
+  }
+
+  fixes, err := cc.FixRequires(fs.Args()...)
+  if err != nil {
+    return err
+  }
+
+  if *diff {
+    for _, fix := range fixes {
+      fmt.Fprint(stdout, fix.Diff())
+    }
+    return nil
+  }
+
+  if err = cc.ApplyFixes(fixes); err != nil {
+    return err
+  }
+
+  for _, fix := range fixes {
+    fmt.Fprintln(stdout, "Fixed", fix.File)
+  }
+  return nil
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+  "bytes"
+  "io/ioutil"
+  "path/filepath"
+  "testing"
+)
+
+func TestFix(t *testing.T) {
+  dir := t.TempDir()
+  src := "goog.provide('a');\ngoog.require('b');\n"
+  ioutil.WriteFile(filepath.Join(dir, "a.js"), []byte(src), 0644)
+  ioutil.WriteFile(filepath.Join(dir, "b.js"), []byte("goog.provide('b');"),
+                   0644)
+
+  var out bytes.Buffer
+  err := runFix([]string{"-root", dir, "-diff"}, &out)
+  expected := "--- a/a.js\n+++ b/a.js\n@@ -1,2 +1,1 @@\n" +
+              " goog.provide('a');\n-goog.require('b');\n"
+  if err != nil || out.String() != expected {
+    t.Errorf("Invalid diff: %q %v", out.String(), err)
+  }
+
+  content, _ := ioutil.ReadFile(filepath.Join(dir, "a.js"))
+  if string(content) != src {
+    t.Error("File is changed in the diff mode.")
+  }
+
+  out.Reset()
+  err = runFix([]string{"-root", dir}, &out)
+  content, _ = ioutil.ReadFile(filepath.Join(dir, "a.js"))
+  if err != nil || out.String() != "Fixed a.js\n" ||
+     string(content) != "goog.provide('a');\n" {
+    t.Error("File is not fixed: ", out.String(), string(content), err)
+  }
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+  "flag"
+  "io"
+)
+
+var graphCommand = &command{
+  name: "graph",
+  usage: "graph [-format dot|json] [entry namespaces...]\n" +
+         "      Exports the dependency graph, or the subgraph reachable " +
+         "from the entries.",
+  run: runGraph,
+}
+
+func runGraph(args []string, stdout io.Writer) error {
+  fs := flag.NewFlagSet("graph", flag.ContinueOnError)
+  sources := addSourceFlags(fs)
+  format := fs.String("format", "dot", "output format: dot or json.")
+  if err := fs.Parse(args); err != nil {
+    return err
+  }
+
+  cc, err := sources.compiler()
+  if err != nil {
+    return err
+  }
+  return cc.ExportDependencyGraph(stdout, *format, fs.Args()...)
+}
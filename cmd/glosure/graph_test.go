@@ -0,0 +1,51 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+  "bytes"
+  "strings"
+  "testing"
+)
+
+const testRoot = "../../test_resources"
+
+func TestGraph(t *testing.T) {
+  var out bytes.Buffer
+  err := runGraph([]string{"-root", testRoot, "pkg2"}, &out)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  if !strings.Contains(out.String(), "\"pkg2\" -> \"pkg3\";") ||
+     strings.Contains(out.String(), "pkg1") {
+    t.Error("Invalid graph: ", out.String())
+  }
+
+  out.Reset()
+  err = runGraph([]string{"-root", testRoot, "-format", "json"}, &out)
+  if err != nil || !strings.Contains(out.String(), "\"namespace\": \"pkg1\"") {
+    t.Error("Invalid JSON graph: ", out.String(), err)
+  }
+
+  if runGraph([]string{"-root", testRoot, "-roots", "closure"}, &out) == nil {
+    t.Error("Invalid source root is accepted.")
+  }
+
+  if runGraph([]string{"-root", testRoot, "-deps", "closure/=deps.js"},
+              &out) == nil {
+    t.Error("Deps file of a missing root is accepted.")
+  }
+}
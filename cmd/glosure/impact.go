@@ -0,0 +1,124 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+  "errors"
+  "flag"
+  "fmt"
+  "io"
+)
+
+var dependentsCommand = &command{
+  name: "dependents",
+  usage: "dependents [-transitive] namespaces...\n" +
+         "      Lists the namespaces requiring the namespaces.",
+  run: runDependents,
+}
+
+var affectedCommand = &command{
+  name: "affected",
+  usage: "affected files...\n" +
+         "      Lists the entry points to rebuild when the files change.",
+  run: runAffected,
+}
+
+var rootsCommand = &command{
+  name: "roots",
+  usage: "roots\n" +
+         "      Lists the namespaces that nothing requires.",
+  run: runRoots,
+}
+
+func printLines(stdout io.Writer, lines []string) {
+  for _, line := range lines {
+    fmt.Fprintln(stdout, line)
+  }
+}
+
+func runDependents(args []string, stdout io.Writer) error {
+  fs := flag.NewFlagSet("dependents", flag.ContinueOnError)
+  sources := addSourceFlags(fs)
+  transitive := fs.Bool("transitive", false,
+                        "also list the namespaces requiring them indirectly.")
+  if err := fs.Parse(args); err != nil {
+    return err
+  }
+
+  if fs.NArg() == 0 {
+    return errors.New("No namespace is given.")
+  }
+
+  cc, err := sources.compiler()
+  if err != nil {
+    return err
+  }
+
+  g := cc.DependencyGraph()
+  if *transitive {
+    deps, err := g.TransitiveDependents(fs.Args()...)
+    if err != nil {
+      return err
+    }
+    printLines(stdout, deps)
+    return nil
+  }
+
+  for _, pkg := range fs.Args() {
+    deps, err := g.Dependents(pkg)
+    if err != nil {
+      return err
+    }
+    printLines(stdout, deps)
+  }
+  return nil
+}
+
+func runAffected(args []string, stdout io.Writer) error {
+  fs := flag.NewFlagSet("affected", flag.ContinueOnError)
+  sources := addSourceFlags(fs)
+  if err := fs.Parse(args); err != nil {
+    return err
+  }
+
+  cc, err := sources.compiler()
+  if err != nil {
+    return err
+  }
+
+  entries, err := cc.AffectedEntryPoints(fs.Args()...)
+  if err != nil {
+    return err
+  }
+  printLines(stdout, entries)
+  return nil
+}
+
+func runRoots(args []string, stdout io.Writer) error {
+  fs := flag.NewFlagSet("roots", flag.ContinueOnError)
+  sources := addSourceFlags(fs)
+  if err := fs.Parse(args); err != nil {
+    return err
+  }
+
+  cc, err := sources.compiler()
+  if err != nil {
+    return err
+  }
+
+  g := cc.DependencyGraph()
+  printLines(stdout, g.Roots())
+  return nil
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+  "bytes"
+  "io"
+  "testing"
+)
+
+func TestImpactQueries(t *testing.T) {
+  queries := [...]struct {
+    Run func([]string, io.Writer) error
+    Args []string
+    Output string
+  }{
+    {runDependents, []string{"pkg3"}, "pkg1\npkg2\n"},
+    {runDependents, []string{"-transitive", "pkg2"}, "pkg1\n"},
+    {runAffected, []string{"pkg3.js"}, "pkg1\n"},
+    {runRoots, []string{}, "pkg1\n"},
+  }
+
+  for _, q := range queries {
+    var out bytes.Buffer
+    err := q.Run(append([]string{"-root", testRoot}, q.Args...), &out)
+    if err != nil || out.String() != q.Output {
+      t.Error("Invalid output for ", q.Args, ": ", out.String(), err)
+    }
+  }
+
+  var out bytes.Buffer
+  if runDependents([]string{"-root", testRoot, "missing"}, &out) == nil {
+    t.Error("Dependents of a missing namespace are listed.")
+  }
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+  "errors"
+  "flag"
+  "fmt"
+  "io"
+)
+
+var lintCommand = &command{
+  name: "lint",
+  usage: "lint [files...]\n" +
+         "      Checks the requires of the files, or of all files in -root. " +
+         "Fails on any problem.",
+  run: runLint,
+}
+
+func runLint(args []string, stdout io.Writer) error {
+  fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+  sources := addSourceFlags(fs)
+  if err := fs.Parse(args); err != nil {
+    return err
+  }
+
+  cc, err := sources.compiler()
+  if err != nil {
+    return err
+  }
+
+  diags, err := cc.Lint(fs.Args()...)
+  if err != nil {
+    return err
+  }
+
+  for _, d := range diags {
+    fmt.Fprintf(stdout, "%s [%s]\n", d, d.Check)
+  }
+
+  if len(diags) != 0 {
+    return errors.New(fmt.Sprintf("%d problems.", len(diags)))
+  }
+  return nil
+}
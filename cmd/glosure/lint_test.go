@@ -0,0 +1,35 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+  "bytes"
+  "testing"
+)
+
+func TestLint(t *testing.T) {
+  var out bytes.Buffer
+  err := runLint([]string{"-root", testRoot, "pkg2.js"}, &out)
+  expected := "pkg2.js:3: warning: Required namespace pkg3 is not used. " +
+              "[unused-require]\n"
+  if err == nil || out.String() != expected {
+    t.Error("Invalid lint output: ", out.String(), err)
+  }
+
+  out.Reset()
+  if err = runLint([]string{"-root", testRoot, "pkg3.js"}, &out); err != nil {
+    t.Error("Clean file fails: ", out.String(), err)
+  }
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command glosure inspects the Closure sources of a Glosure compiler.
+//
+// Usage:
+//
+//    glosure [glog flags] <command> [flags] [arguments]
+//
+// Run "glosure help" for the list of commands.
+package main
+
+import (
+  "errors"
+  "flag"
+  "fmt"
+  "io"
+  "os"
+  "strings"
+
+  "github.com/soheilhy/glosure"
+)
+
+type command struct {
+  name string
+  usage string
+  // Runs the command with its arguments and writes its output to stdout.
+  run func(args []string, stdout io.Writer) error
+}
+
+var commands = []*command{
+  buildCommand,
+  graphCommand,
+  conflictsCommand,
+  lintCommand,
+  fixCommand,
+  dependentsCommand,
+  affectedCommand,
+  rootsCommand,
+  whyCommand,
+}
+
+// Flags selecting the source roots, shared by all commands.
+type sourceFlags struct {
+  root *string
+  roots *string
+  deps *string
+  index *string
+}
+
+func addSourceFlags(fs *flag.FlagSet) *sourceFlags {
+  return &sourceFlags{
+    root: fs.String("root", ".", "directory of the JavaScript sources."),
+    roots: fs.String("roots", "",
+                     "additional source roots as comma separated prefix=dir " +
+                     "pairs (e.g., closure/=../closure-library/closure)."),
+    deps: fs.String("deps", "",
+                    "deps.js files listing the files of the additional " +
+                    "roots as comma\nseparated prefix=file pairs, with " +
+                    "files relative to their root\n(e.g., " +
+                    "closure/=goog/deps.js)."),
+    index: fs.String("index", "",
+                     "file keeping the scans of the sources between runs."),
+  }
+}
+
+// Creates a compiler for the source roots in the flags.
+func (f *sourceFlags) compiler() (*glosure.Compiler, error) {
+  cc := glosure.NewCompiler(*f.root)
+  cc.IndexPath = *f.index
+  for _, root := range splitList(*f.roots) {
+    parts := strings.SplitN(root, "=", 2)
+    if len(parts) != 2 || parts[1] == "" {
+      return nil, errors.New("Invalid source root: " + root)
+    }
+
+    cc.Roots = append(cc.Roots, glosure.SourceRoot{
+      Name: parts[1],
+      Sources: glosure.DirSources(parts[1]),
+      Prefix: parts[0],
+    })
+  }
+
+  for _, deps := range splitList(*f.deps) {
+    parts := strings.SplitN(deps, "=", 2)
+    found := false
+    for i := range cc.Roots {
+      prefix := strings.Trim(cc.Roots[i].Prefix, "/")
+      if len(parts) == 2 && parts[1] != "" &&
+         prefix == strings.Trim(parts[0], "/") {
+        cc.Roots[i].DepsFiles = append(cc.Roots[i].DepsFiles, parts[1])
+        found = true
+      }
+    }
+
+    if !found {
+      return nil, errors.New("Invalid deps file: " + deps)
+    }
+  }
+  return &cc, nil
+}
+
+// Splits a comma separated list of flag values.
+func splitList(list string) []string {
+  if list == "" {
+    return nil
+  }
+  return strings.Split(list, ",")
+}
+
+func usage() {
+  fmt.Fprintln(os.Stderr,
+               "Usage: glosure [glog flags] <command> [flags] [arguments]")
+  fmt.Fprintln(os.Stderr, "\nCommands:")
+  for _, cmd := range commands {
+    fmt.Fprintf(os.Stderr, "  %s\n", cmd.usage)
+  }
+}
+
+func main() {
+  flag.Usage = usage
+  flag.Parse()
+
+  if flag.NArg() == 0 || flag.Arg(0) == "help" {
+    usage()
+    os.Exit(2)
+  }
+
+  for _, cmd := range commands {
+    if cmd.name != flag.Arg(0) {
+      continue
+    }
+
+    err := cmd.run(flag.Args()[1:], os.Stdout)
+    if err != nil {
+      fmt.Fprintln(os.Stderr, "glosure " + cmd.name + ": " + err.Error())
+      os.Exit(1)
+    }
+    return
+  }
+
+  fmt.Fprintln(os.Stderr, "Unknown command: " + flag.Arg(0))
+  usage()
+  os.Exit(2)
+}
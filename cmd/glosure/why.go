@@ -0,0 +1,68 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+  "errors"
+  "flag"
+  "fmt"
+  "io"
+)
+
+var whyCommand = &command{
+  name: "why",
+  usage: "why [-all] entry namespace\n" +
+         "      Prints the shortest chain of requires from the entry to the " +
+         "namespace,\n      or all of them.",
+  run: runWhy,
+}
+
+func runWhy(args []string, stdout io.Writer) error {
+  fs := flag.NewFlagSet("why", flag.ContinueOnError)
+  sources := addSourceFlags(fs)
+  all := fs.Bool("all", false, "print all chains, shortest first.")
+  if err := fs.Parse(args); err != nil {
+    return err
+  }
+
+  if fs.NArg() != 2 {
+    return errors.New("Expected an entry and a namespace.")
+  }
+
+  cc, err := sources.compiler()
+  if err != nil {
+    return err
+  }
+
+  entry, target := fs.Arg(0), fs.Arg(1)
+  limit := 1
+  if *all {
+    limit = 0
+  }
+
+  paths, err := cc.RequirePaths(entry, target, limit)
+  if err != nil {
+    return err
+  }
+
+  if len(paths) == 0 {
+    return errors.New(entry + " does not depend on " + target + ".")
+  }
+
+  for _, path := range paths {
+    fmt.Fprintln(stdout, path)
+  }
+  return nil
+}
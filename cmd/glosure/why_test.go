@@ -0,0 +1,34 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+  "bytes"
+  "testing"
+)
+
+func TestWhy(t *testing.T) {
+  var out bytes.Buffer
+  err := runWhy([]string{"-root", testRoot, "-all", "pkg1", "pkg3"}, &out)
+  expected := "pkg1 (pkg1.js:5) -> pkg3 (pkg3.js)\n" +
+              "pkg1 (pkg1.js:4) -> pkg2 (pkg2.js:3) -> pkg3 (pkg3.js)\n"
+  if err != nil || out.String() != expected {
+    t.Error("Invalid paths: ", out.String(), err)
+  }
+
+  if runWhy([]string{"-root", testRoot, "pkg3", "pkg1"}, &out) == nil {
+    t.Error("No error for an entry that does not depend on the namespace.")
+  }
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "fmt"
+  "io/fs"
+  "strings"
+
+  "github.com/soheilhy/glosure/depgraph"
+)
+
+// ConflictPolicy decides what happens when more than one file provides the
+// same namespace. In all cases the graph uses the first provider, in the order
+// of Compiler.Roots and then the file names.
+type ConflictPolicy string
+const (
+  // Conflicts are reported as warnings (default).
+  WarnOnConflict ConflictPolicy = "warn"
+  // Compiling a target that depends on a conflicting namespace fails.
+  FailOnConflict = "fail"
+  // Conflicts between roots are resolved in favor of the earlier root without
+  // a warning. Conflicts within a root are still reported as warnings.
+  ResolveByRootPriority = "root-priority"
+)
+
+// Returns the index of the source root of a file, or -1 if it is not in any
+// root.
+func (cc *Compiler) rootIndex(name string) int {
+  for i, root := range cc.sourceRoots() {
+    if !strings.HasPrefix(name, root.Prefix) {
+      continue
+    }
+
+    if _, err := fs.Stat(root.Sources, name[len(root.Prefix):]); err == nil {
+      return i
+    }
+  }
+  return -1
+}
+
+// Whether the policy resolves the conflict without reporting it.
+func (cc *Compiler) isResolvedConflict(c depgraph.Conflict) bool {
+  if cc.ConflictPolicy != ResolveByRootPriority {
+    return false
+  }
+
+  first := cc.rootIndex(c.Paths[0])
+  for _, path := range c.Paths[1:] {
+    if cc.rootIndex(path) == first {
+      return false
+    }
+  }
+  return true
+}
+
+// Returns the namespace conflicts of the graph as diagnostics according to
+// Compiler.ConflictPolicy. If pkgs is not nil, only the conflicts of pkgs are
+// returned.
+func (cc *Compiler) conflictDiagnostics(g depgraph.DependencyGraph,
+                                        pkgs map[string]bool) []Diagnostic {
+  diags := []Diagnostic{}
+  for _, c := range g.Conflicts() {
+    if (pkgs != nil && !pkgs[c.Pkg]) || cc.isResolvedConflict(c) {
+      continue
+    }
+
+    var severity Severity = SeverityWarning
+    if cc.ConflictPolicy == FailOnConflict {
+      severity = SeverityError
+    }
+
+    diags = append(diags, Diagnostic{
+      Severity: severity,
+      File: c.Paths[1],
+      Message: fmt.Sprintf("Namespace %s is provided by %s; using %s.", c.Pkg,
+                           strings.Join(c.Paths, ", "), c.Paths[0]),
+    })
+  }
+  return diags
+}
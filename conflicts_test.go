@@ -0,0 +1,69 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "testing"
+  "testing/fstest"
+)
+
+func TestConflictPolicies(t *testing.T) {
+  cc := newRootsCompiler()
+  g := cc.DependencyGraph()
+
+  diags := cc.conflictDiagnostics(g, nil)
+  expected := "Namespace goog.dom is provided by closure/goog/dom.js, " +
+              "third_party/dom.js; using closure/goog/dom.js."
+  if len(diags) != 1 || diags[0].Severity != SeverityWarning ||
+     diags[0].Message != expected || diags[0].File != "third_party/dom.js" {
+    t.Fatal("Invalid conflict diagnostics: ", diags)
+  }
+
+  cc.ConflictPolicy = FailOnConflict
+  diags = cc.conflictDiagnostics(g, map[string]bool{"goog.dom": true})
+  if len(diags) != 1 || diags[0].Severity != SeverityError {
+    t.Error("Conflict does not fail: ", diags)
+  }
+
+  if len(cc.conflictDiagnostics(g, map[string]bool{"app": true})) != 0 {
+    t.Error("Conflict of another namespace is reported.")
+  }
+
+  cc.ConflictPolicy = ResolveByRootPriority
+  if diags = cc.conflictDiagnostics(g, nil); len(diags) != 0 {
+    t.Error("Conflict between roots is not resolved: ", diags)
+  }
+
+  if len(cc.Status().Conflicts) != 1 {
+    t.Error("Conflict is not in the status: ", cc.Status().Conflicts)
+  }
+}
+
+func TestConflictWithinRoot(t *testing.T) {
+  cc := NewCompilerWithSources(fstest.MapFS{
+    "a.js": {Data: []byte("goog.provide('x');")},
+    "b.js": {Data: []byte("goog.provide('x');")},
+  })
+  cc.ConflictPolicy = ResolveByRootPriority
+
+  g := cc.DependencyGraph()
+  if g.Nodes["x"].Path != "a.js" {
+    t.Error("First file is not used: ", g.Nodes["x"].Path)
+  }
+
+  if len(cc.conflictDiagnostics(g, nil)) != 1 {
+    t.Error("Conflict within a root is resolved.")
+  }
+}
@@ -0,0 +1,231 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "bytes"
+  "errors"
+  "fmt"
+  "io/fs"
+  "io/ioutil"
+  "net/http"
+  "os"
+  "os/exec"
+  "path/filepath"
+  "strings"
+  "time"
+
+  "github.com/golang/glog"
+)
+
+type CssRenaming string
+const (
+  NoRenaming CssRenaming = "NONE"
+  DebugRenaming = "DEBUG"
+  ClosureRenaming = "CLOSURE"
+)
+
+// Creates an http.Handler serving stylesheets compiled by Closure
+// Stylesheets. The handler uses the compiler as is, so JavaScript served by
+// ServeHttp with the same compiler shares its options, outputs and
+// statistics.
+func StylesheetServer(cc *Compiler) http.Handler {
+  return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+    ServeCssHttp(res, req, cc)
+  })
+}
+
+// Glosure's handler function for compiled stylesheets.
+func ServeCssHttp(res http.ResponseWriter, req *http.Request, cc *Compiler) {
+  path, hash := cc.parseFingerprintedPath(req.URL.Path)
+
+  if !cc.isCompiledCss(path) {
+    cc.ErrorHandler(res, req)
+    return
+  }
+
+  if cc.getSourceCssName(path) == "" {
+    cc.ErrorHandler(res, req)
+    return
+  }
+
+  forceCompile := req.URL.Query().Get("force") == "1"
+  if !cc.CompileOnDemand || (!forceCompile && cc.cssIsAlreadyCompiled(path)) {
+    cc.stats.recordHit()
+    if cc.CompileOnDemand {
+      cc.revalidateSource(cc.getSourceCssName(path),
+                          cc.getCompiledCssName(path))
+    }
+    cc.serveOutput(res, req, path, hash)
+    return
+  }
+
+  cc.stats.recordMiss()
+  err := cc.CompileCss(path)
+  if err != nil {
+    glog.Error(err)
+    cc.ErrorHandler(res, req)
+    return
+  }
+
+  glog.Info("Stylesheet is successfully compiled: ", path)
+  cc.serveOutput(res, req, path, hash)
+}
+
+func (cc *Compiler) isCompiledCss(path string) bool {
+  return strings.HasSuffix(path, cc.CompiledCssSuffix)
+}
+
+// Returns the name of the first existing source in Compiler.Sources for the
+// compiled stylesheet, or an empty string if there is none.
+func (cc *Compiler) getSourceCssName(relPath string) string {
+  base := relPath[:len(relPath) - len(cc.CompiledCssSuffix)]
+  for _, suffix := range cc.CssSourceSuffixes {
+    srcName := cleanName(base + suffix)
+    if _, err := fs.Stat(cc.mountedSources(), srcName); err == nil {
+      return srcName
+    }
+  }
+  return ""
+}
+
+// Returns the name of the compiled stylesheet in Compiler.Outputs.
+func (cc *Compiler) getCompiledCssName(relPath string) string {
+  return cleanName(relPath)
+}
+
+// Returns the name of the CSS renaming map in Compiler.Outputs, which is shared
+// by the stylesheet and the JavaScript of the target. relPath can be the path
+// of either of the compiled outputs.
+func (cc *Compiler) getCssRenamingMapName(relPath string) string {
+  base := relPath
+  switch {
+  case cc.isCompiledCss(relPath):
+    base = relPath[:len(relPath) - len(cc.CompiledCssSuffix)]
+  case cc.isCompiledJavascript(relPath):
+    base = relPath[:len(relPath) - len(cc.CompiledSuffix)]
+  }
+  return cleanName(base + cc.CssRenamingMapSuffix)
+}
+
+func (cc *Compiler) cssIsAlreadyCompiled(path string) bool {
+  return cc.isUpToDate(cc.getSourceCssName(path), cc.getCompiledCssName(path))
+}
+
+// Compiles the stylesheet of the given target using Closure Stylesheets. If
+// renaming is enabled, the renaming map is stored next to the output and is
+// passed to the JavaScript compilation of the same target. If the stylesheet
+// cannot be compiled, the error is a *CompilationError.
+func (cc *Compiler) CompileCss(relOutPath string) (err error) {
+  start := time.Now()
+  var diags []Diagnostic
+  defer func() {
+    if err == nil {
+      cc.updateManifest(cc.getCompiledCssName(relOutPath))
+    }
+    err = cc.recordCompile(relOutPath, "stylesheets", start, diags, err)
+  }()
+
+  if cc.StylesheetsJarPath == "" {
+    return errors.New("No closure stylesheets jar is set.")
+  }
+
+  _, err = exec.LookPath("java")
+  if err != nil {
+    return errors.New("No java found in $PATH.")
+  }
+
+  srcName := cc.getSourceCssName(relOutPath)
+  if srcName == "" {
+    return errors.New(fmt.Sprintf("No stylesheet found for %s in %s.",
+                                  relOutPath, cc.Root))
+  }
+
+  tmpDir, err := ioutil.TempDir("", "glosure")
+  if err != nil {
+    return err
+  }
+  defer os.RemoveAll(tmpDir)
+
+  srcPaths, err := cc.localSourcePaths([]string{srcName}, tmpDir)
+  if err != nil {
+    return err
+  }
+
+  outPath := filepath.Join(tmpDir, "out" + cc.CompiledCssSuffix)
+  mapPath := filepath.Join(tmpDir, "cssmap" + cc.SourceSuffix)
+  srcHashes := cc.hashSources(srcName)
+  stdErr, err := cc.runJava(cc.getStylesheetsArgs(srcPaths[0], outPath,
+                                                  mapPath))
+  diags = parseJavaDiagnostics(stdErr, map[string]string{srcPaths[0]: srcName})
+  if err != nil {
+    return err
+  }
+
+  outName := cc.getCompiledCssName(relOutPath)
+  err = cc.storeOutput(outName, outPath)
+  if err != nil {
+    return err
+  }
+
+  err = cc.storeRenamingMap(cc.getCssRenamingMapName(relOutPath), mapPath)
+  if err != nil {
+    return err
+  }
+
+  cc.recordSources(outName, srcHashes)
+  return cc.processOutput(outName)
+}
+
+// Stores the renaming map generated in mapPath, if any. The map is rewritten
+// only if it is changed, because a newer map triggers the compilation of the
+// JavaScript of the target.
+func (cc *Compiler) storeRenamingMap(mapName string, mapPath string) error {
+  content, err := ioutil.ReadFile(mapPath)
+  if os.IsNotExist(err) {
+    return nil
+  }
+
+  if err != nil {
+    return err
+  }
+
+  prev, err := cc.Outputs.ReadFile(mapName)
+  if err == nil && bytes.Equal(prev, content) {
+    return nil
+  }
+  return cc.Outputs.WriteFile(mapName, content)
+}
+
+func (cc *Compiler) getStylesheetsArgs(srcPath string, outPath string,
+                                       mapPath string) []string {
+  args := []string{
+    "-jar", cc.StylesheetsJarPath,
+    "--output-file", outPath,
+  }
+
+  if cc.CssRenaming != "" && cc.CssRenaming != NoRenaming {
+    args = append(args,
+                  "--rename", string(cc.CssRenaming),
+                  "--output-renaming-map-format", "CLOSURE_COMPILED",
+                  "--output-renaming-map", mapPath)
+  }
+
+  if cc.Formatting == PrettyPrint {
+    args = append(args, "--pretty-print")
+  }
+
+  return append(args, srcPath)
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "io/ioutil"
+  "net/http"
+  "net/http/httptest"
+  "path/filepath"
+  "testing"
+  "time"
+)
+
+func TestGetSourceCssName(t *testing.T) {
+  cc := NewCompiler("./test_resources")
+  src := cc.getSourceCssName("style.min.css")
+  if src != "style.gss" {
+    t.Error("Invalid stylesheet source: ", src)
+  }
+
+  if src = cc.getSourceCssName("nostyle.min.css"); src != "" {
+    t.Error("Found a source for a non-existing stylesheet: ", src)
+  }
+}
+
+func TestCssRenamingMapName(t *testing.T) {
+  cc := NewCompiler("./test_resources")
+  jsMap := cc.getCssRenamingMapName("/app.min.js")
+  cssMap := cc.getCssRenamingMapName("/app.min.css")
+  if jsMap != cssMap || jsMap != "app.cssmap.js" {
+    t.Error("JavaScript and stylesheet do not share the renaming map: ", jsMap,
+            cssMap)
+  }
+}
+
+func TestStylesheetsArgs(t *testing.T) {
+  cc := NewCompiler("./test_resources")
+  cc.StylesheetsJarPath = "stylesheets.jar"
+  cc.CssRenaming = ClosureRenaming
+  args := cc.getStylesheetsArgs("test_resources/style.gss", "out.min.css",
+                                "out.cssmap.js")
+
+  expected := []string{
+    "-jar", "stylesheets.jar",
+    "--output-file", "out.min.css",
+    "--rename", "CLOSURE",
+    "--output-renaming-map-format", "CLOSURE_COMPILED",
+    "--output-renaming-map", "out.cssmap.js",
+    "test_resources/style.gss",
+  }
+  if len(args) != len(expected) {
+    t.Fatal("Invalid closure stylesheets arguments: ", args)
+  }
+  for i := range expected {
+    if args[i] != expected[i] {
+      t.Error("Invalid closure stylesheets argument: ", args[i], expected[i])
+    }
+  }
+}
+
+func TestCompileCssWithoutJar(t *testing.T) {
+  cc := NewCompiler("./test_resources")
+  if err := cc.CompileCss("style.min.css"); err == nil {
+    t.Error("Compiled a stylesheet without closure stylesheets.")
+  }
+}
+
+func TestStoreRenamingMap(t *testing.T) {
+  cc := NewCompiler("./test_resources")
+  cc.Outputs = NewMemoryStore()
+  mapPath := filepath.Join(t.TempDir(), "cssmap.js")
+
+  err := cc.storeRenamingMap("app.cssmap.js", mapPath)
+  if err != nil {
+    t.Fatal("Cannot skip a missing renaming map: ", err)
+  }
+
+  ioutil.WriteFile(mapPath, []byte("goog.setCssNameMapping({});"), 0644)
+  cc.storeRenamingMap("app.cssmap.js", mapPath)
+  stat, err := cc.Outputs.Stat("app.cssmap.js")
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  time.Sleep(10 * time.Millisecond)
+  cc.storeRenamingMap("app.cssmap.js", mapPath)
+  newStat, _ := cc.Outputs.Stat("app.cssmap.js")
+  if !newStat.ModTime().Equal(stat.ModTime()) {
+    t.Error("An unchanged renaming map is rewritten.")
+  }
+}
+
+func TestServeCssHttpNotFound(t *testing.T) {
+  cc := NewCompiler("./test_resources")
+  for _, path := range []string{"/style.gss", "/nostyle.min.css"} {
+    res := httptest.NewRecorder()
+    req, _ := http.NewRequest("GET", path, nil)
+    ServeCssHttp(res, req, &cc)
+    if res.Code != http.StatusNotFound {
+      t.Error("Expected 404 for ", path, " got ", res.Code)
+    }
+  }
+}
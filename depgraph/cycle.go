@@ -0,0 +1,169 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depgraph
+
+import (
+  "fmt"
+  "sort"
+  "strings"
+)
+
+// Cycle is a circular chain of requires: every step requires the next one, and
+// the last step requires the first.
+type Cycle struct {
+  Steps []CycleStep
+}
+
+// CycleStep is a package in a chain of requires (see also RequirePath).
+type CycleStep struct {
+  Pkg string `json:"namespace"`
+  Path string `json:"file"`
+  // Line of the require of the next step in Path, or 0 if unknown.
+  Line int `json:"line,omitempty"`
+}
+
+// Formats the cycle as "a (a.js:3) -> b (b.js:7) -> a".
+func (c Cycle) String() string {
+  parts := make([]string, 0, len(c.Steps) + 1)
+  for _, step := range c.Steps {
+    pos := step.Path
+    if step.Line != 0 {
+      pos = fmt.Sprintf("%s:%d", step.Path, step.Line)
+    }
+    parts = append(parts, fmt.Sprintf("%s (%s)", step.Pkg, pos))
+  }
+
+  if len(c.Steps) != 0 {
+    parts = append(parts, c.Steps[0].Pkg)
+  }
+  return strings.Join(parts, " -> ")
+}
+
+// Whether any step of the cycle is the given package.
+func (c Cycle) Contains(pkg string) bool {
+  for _, step := range c.Steps {
+    if step.Pkg == pkg {
+      return true
+    }
+  }
+  return false
+}
+
+// Returns the cycle rotated to start with its smallest package, so that the
+// same cycle found from different edges compares equal.
+func (c Cycle) canonical() Cycle {
+  first := 0
+  for i, step := range c.Steps {
+    if step.Pkg < c.Steps[first].Pkg {
+      first = i
+    }
+  }
+
+  steps := append([]CycleStep{}, c.Steps[first:]...)
+  steps = append(steps, c.Steps[:first]...)
+  return Cycle{steps}
+}
+
+func (c Cycle) key() string {
+  pkgs := make([]string, 0, len(c.Steps))
+  for _, step := range c.Steps {
+    pkgs = append(pkgs, step.Pkg)
+  }
+  return strings.Join(pkgs, "\x00")
+}
+
+// CycleError is returned by AddDependency for a dependency that closes a cycle.
+type CycleError struct {
+  Cycle Cycle
+}
+
+func (e *CycleError) Error() string {
+  return "Circular dependency: " + e.Cycle.String()
+}
+
+// Returns the shortest chain of dependencies from one package to another, or
+// nil if there is none.
+func (g *DependencyGraph) shortestPath(from string, to string) []*Node {
+  start, ok := g.Nodes[from]
+  if !ok {
+    return nil
+  }
+  return shortestPathAvoiding(start, to, nil)
+}
+
+// Returns the shortest chain of dependencies from start to the package to,
+// without the edges for which blocked returns true, or nil if there is none.
+// Dependencies are followed in their order, so ties go to earlier requires.
+func shortestPathAvoiding(start *Node, to string,
+                          blocked func(from *Node, to *Node) bool) []*Node {
+  prev := map[*Node]*Node{start: nil}
+  queue := []*Node{start}
+  for len(queue) != 0 {
+    node := queue[0]
+    queue = queue[1:]
+    if node.Pkg == to {
+      path := []*Node{}
+      for n := node; n != nil; n = prev[n] {
+        path = append([]*Node{n}, path...)
+      }
+      return path
+    }
+
+    for e := node.Dependencies.Front(); e != nil; e = e.Next() {
+      dep := e.Value.(*Node)
+      if _, seen := prev[dep]; !seen && (blocked == nil ||
+                                         !blocked(node, dep)) {
+        prev[dep] = node
+        queue = append(queue, dep)
+      }
+    }
+  }
+  return nil
+}
+
+// Returns the cycle closed by a rejected edge: the edge itself followed by the
+// shortest chain of dependencies back to its source.
+func (g *DependencyGraph) cycleOf(e edge) Cycle {
+  from := g.Nodes[e.from]
+  steps := []CycleStep{{from.Pkg, from.Path, g.lines[e]}}
+  path := g.shortestPath(e.to, e.from)
+  for i := 0; i < len(path) - 1; i++ {
+    next := path[i + 1].Pkg
+    steps = append(steps, CycleStep{path[i].Pkg, path[i].Path,
+                                    g.lines[edge{path[i].Pkg, next}]})
+  }
+  return Cycle{steps}.canonical()
+}
+
+// Returns every cycle in the graph, sorted by their packages. Each dependency
+// rejected by AddDependency closes a cycle; cycles closed by more than one
+// rejected dependency are reported once.
+func (g *DependencyGraph) Cycles() []Cycle {
+  seen := make(map[string]bool)
+  cycles := []Cycle{}
+  for _, e := range g.cyclic {
+    c := g.cycleOf(e)
+    if seen[c.key()] {
+      continue
+    }
+    seen[c.key()] = true
+    cycles = append(cycles, c)
+  }
+
+  sort.Slice(cycles, func(i, j int) bool {
+    return cycles[i].key() < cycles[j].key()
+  })
+  return cycles
+}
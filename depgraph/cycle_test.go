@@ -0,0 +1,75 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depgraph
+
+import (
+  "testing"
+)
+
+func TestCycleError(t *testing.T) {
+  graph := New()
+  graph.AddFile("a", "a.js")
+  graph.AddFile("b", "b.js")
+  graph.AddFile("c", "c.js")
+  graph.AddRequire("a", "b", 3)
+  graph.AddRequire("b", "c", 4)
+
+  err := graph.AddRequire("c", "a", 5)
+  cycleErr, ok := err.(*CycleError)
+  if !ok {
+    t.Fatal("Cycle is not rejected: ", err)
+  }
+
+  expected := "Circular dependency: a (a.js:3) -> b (b.js:4) -> c (c.js:5) -> a"
+  if cycleErr.Error() != expected {
+    t.Error("Invalid cycle: ", cycleErr.Error())
+  }
+}
+
+func TestCycles(t *testing.T) {
+  graph := New()
+  for _, pkg := range []string{"a", "b", "c", "d", "e"} {
+    graph.AddFile(pkg, pkg + ".js")
+  }
+
+  graph.AddRequire("a", "b", 1)
+  graph.AddRequire("b", "a", 2)
+  graph.AddRequire("c", "d", 3)
+  graph.AddRequire("d", "e", 4)
+  graph.AddRequire("e", "c", 5)
+  graph.AddRequire("e", "e", 6)
+  // Closes the same cycle as the first rejected edge.
+  graph.AddRequire("b", "a", 2)
+
+  cycles := graph.Cycles()
+  expected := []string{
+    "a (a.js:1) -> b (b.js:2) -> a",
+    "c (c.js:3) -> d (d.js:4) -> e (e.js:5) -> c",
+    "e (e.js:6) -> e",
+  }
+  if len(cycles) != len(expected) {
+    t.Fatal("Invalid cycles: ", cycles)
+  }
+
+  for i, c := range cycles {
+    if c.String() != expected[i] {
+      t.Error("Invalid cycle: ", c.String(), expected[i])
+    }
+  }
+
+  if !cycles[1].Contains("d") || cycles[1].Contains("a") {
+    t.Error("Invalid packages in the cycle: ", cycles[1])
+  }
+}
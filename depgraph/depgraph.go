@@ -17,20 +17,169 @@ package depgraph
 import (
   "container/list"
   "errors"
+  "sort"
 )
 
+// DependencyGraph is the graph of Closure packages and their requires. A graph
+// can be read from many goroutines at once, but must not be modified while it
+// is read.
 type DependencyGraph struct {
   Nodes map[string]*Node
+
+  // Lines of requires keyed by the edge.
+  lines map[edge]int
+  // Edges rejected because they close a cycle, in the order of addition.
+  cyclic []edge
+  // Files of the namespaces provided by more than one file, in the order of
+  // addition.
+  providers map[string][]string
+  // Type-only and forward-declared dependencies.
+  weak map[edge]weakEdge
+}
+
+// EdgeKind is the kind of a dependency, after the Closure call declaring it.
+type EdgeKind string
+
+const (
+  // A goog.require: the dependency is loaded before its dependent.
+  StrongEdge EdgeKind = "require"
+  // A goog.requireType: the dependency is only referenced in types.
+  TypeEdge EdgeKind = "requireType"
+  // A goog.forwardDeclare: the dependency may not even be provided.
+  ForwardDeclareEdge EdgeKind = "forwardDeclare"
+)
+
+type weakEdge struct {
+  kind EdgeKind
+  line int
+}
+
+type edge struct {
+  from string
+  to string
 }
 
 func New() DependencyGraph {
-  return DependencyGraph{make(map[string]*Node)}
+  return DependencyGraph{Nodes: make(map[string]*Node)}
+}
+
+// Returns a deep copy of the graph, which can be modified without changing g.
+// Dependencies on nodes that are not in g (e.g., out of a subgraph) are
+// dropped.
+func (g *DependencyGraph) Clone() DependencyGraph {
+  c := DependencyGraph{
+    Nodes: make(map[string]*Node, len(g.Nodes)),
+    lines: make(map[edge]int, len(g.lines)),
+    cyclic: append([]edge{}, g.cyclic...),
+  }
+
+  copies := make(map[*Node]*Node, len(g.Nodes))
+  for pkg, node := range g.Nodes {
+    copies[node] = &Node{
+      Pkg: node.Pkg,
+      Path: node.Path,
+      Dependencies: list.New(),
+      Dependents: list.New(),
+      dependencySet: make(map[*Node]bool),
+      order: node.order,
+    }
+    c.Nodes[pkg] = copies[node]
+  }
+
+  for node, copied := range copies {
+    for e := node.Dependencies.Front(); e != nil; e = e.Next() {
+      if dep, ok := copies[e.Value.(*Node)]; ok {
+        copied.Dependencies.PushBack(dep)
+        copied.dependencySet[dep] = true
+      }
+    }
+
+    for e := node.Dependents.Front(); e != nil; e = e.Next() {
+      if dependent, ok := copies[e.Value.(*Node)]; ok {
+        copied.Dependents.PushBack(dependent)
+      }
+    }
+  }
+
+  for e, line := range g.lines {
+    c.lines[e] = line
+  }
+
+  if g.providers != nil {
+    c.providers = make(map[string][]string, len(g.providers))
+    for pkg, paths := range g.providers {
+      c.providers[pkg] = append([]string{}, paths...)
+    }
+  }
+
+  if g.weak != nil {
+    c.weak = make(map[edge]weakEdge, len(g.weak))
+    for e, w := range g.weak {
+      c.weak[e] = w
+    }
+  }
+  return c
 }
 
+// Adds a file providing the package. If the package is already provided by
+// another file, the first file is kept and the conflict is recorded.
 func (g *DependencyGraph) AddFile(pkg string, path string) {
-  g.Nodes[pkg] = &Node{pkg, path, list.New()}
+  node, ok := g.Nodes[pkg]
+  if !ok {
+    g.Nodes[pkg] = &Node{
+      Pkg: pkg,
+      Path: path,
+      Dependencies: list.New(),
+      Dependents: list.New(),
+      dependencySet: make(map[*Node]bool),
+      order: len(g.Nodes),
+    }
+    return
+  }
+
+  if g.providers == nil {
+    g.providers = make(map[string][]string)
+  }
+
+  paths, ok := g.providers[pkg]
+  if !ok {
+    paths = []string{node.Path}
+  }
+
+  for _, p := range paths {
+    if p == path {
+      return
+    }
+  }
+  g.providers[pkg] = append(paths, path)
 }
 
+// Conflict is a package provided by more than one file. The first file is the
+// one in the graph.
+type Conflict struct {
+  Pkg string `json:"namespace"`
+  Paths []string `json:"files"`
+}
+
+// Returns the packages provided by more than one file, sorted by package.
+func (g *DependencyGraph) Conflicts() []Conflict {
+  conflicts := []Conflict{}
+  for pkg, paths := range g.providers {
+    conflicts = append(conflicts, Conflict{pkg, paths})
+  }
+
+  sort.Slice(conflicts, func(i, j int) bool {
+    return conflicts[i].Pkg < conflicts[j].Pkg
+  })
+  return conflicts
+}
+
+// Adds a dependency of one package on another. Returns a *CycleError, and
+// leaves the graph unchanged, if the dependency would close a cycle.
+//
+// The graph keeps its packages in a topological order, dependencies first, and
+// only reorders the packages between the two ends of a dependency that breaks
+// the order. The same search finds the cycles.
 func (g *DependencyGraph) AddDependency(from string, to string) error {
   fromNode, ok := g.Nodes[from]
   if !ok {
@@ -42,95 +191,171 @@ func (g *DependencyGraph) AddDependency(from string, to string) error {
     return errors.New("Package not found: " + to)
   }
 
-  if toNode.isRecursivelyDependentOn(from) {
-    return errors.New("Circular dependency between " + from + " and " + to)
+  if fromNode.dependencySet[toNode] {
+    return nil
+  }
+
+  if from == to || !g.reorder(fromNode, toNode) {
+    g.cyclic = append(g.cyclic, edge{from, to})
+    return &CycleError{g.cycleOf(edge{from, to})}
   }
 
+  fromNode.dependencySet[toNode] = true
   fromNode.Dependencies.PushBack(toNode)
+  toNode.Dependents.PushBack(fromNode)
   return nil
 }
 
-func (g *DependencyGraph) GetDependenciesOfPackage(pkg string) []*Node {
-  node, ok := g.Nodes[pkg]
-  if !ok {
-    return nil
+// Restores the topological order before adding a dependency of from on to,
+// moving to and its dependencies before from and its dependents. Returns false
+// if to depends on from. See Pearce and Kelly, "A Dynamic Topological Sort
+// Algorithm for Directed Acyclic Graphs".
+func (g *DependencyGraph) reorder(from *Node, to *Node) bool {
+  if to.order < from.order {
+    return true
   }
 
-  return g.GetDependencies([]*Node{node})
-}
-
-func (g *DependencyGraph) GetDependencies(nodes []*Node) []*Node {
-  return g.getDependencies(nodes, make([]*Node, 0))
-}
+  // Dependents of from placed before to.
+  dependents := []*Node{}
+  seen := map[*Node]bool{from: true}
+  stack := []*Node{from}
+  for len(stack) != 0 {
+    node := stack[len(stack) - 1]
+    stack = stack[:len(stack) - 1]
+    dependents = append(dependents, node)
+    for e := node.Dependents.Front(); e != nil; e = e.Next() {
+      dep := e.Value.(*Node)
+      if dep == to {
+        return false
+      }
 
-func (g *DependencyGraph) getDependencies(nodes []*Node, deps []*Node) []*Node {
-  for _, node := range nodes {
-    if contains(deps, node) {
-      continue
+      if !seen[dep] && dep.order < to.order {
+        seen[dep] = true
+        stack = append(stack, dep)
+      }
     }
+  }
 
+  // Dependencies of to placed after from.
+  dependencies := []*Node{}
+  seen = map[*Node]bool{to: true}
+  stack = []*Node{to}
+  for len(stack) != 0 {
+    node := stack[len(stack) - 1]
+    stack = stack[:len(stack) - 1]
+    dependencies = append(dependencies, node)
     for e := node.Dependencies.Front(); e != nil; e = e.Next() {
-      eDeps := g.getDependencies([]*Node{e.Value.(*Node)}, deps)
-      for _, dependencyNode := range eDeps {
-        if contains(deps, dependencyNode) {
-          continue
-        }
-        deps = append(deps, dependencyNode)
+      dep := e.Value.(*Node)
+      if !seen[dep] && dep.order > from.order {
+        seen[dep] = true
+        stack = append(stack, dep)
       }
     }
-    deps = append(deps, node)
   }
-  return deps
-}
 
-type Node struct {
-  Pkg string
-  Path string
-  Dependencies *list.List
-}
+  byOrder := func(nodes []*Node) {
+    sort.Slice(nodes, func(i, j int) bool {
+      return nodes[i].order < nodes[j].order
+    })
+  }
+  byOrder(dependents)
+  byOrder(dependencies)
 
-func contains(nodes []*Node, newNode *Node) bool {
+  // The moved nodes take the same positions, dependencies first.
+  nodes := append(dependencies, dependents...)
+  orders := make([]int, 0, len(nodes))
   for _, node := range nodes {
-    if node.Path == newNode.Path {
-      return true
-    }
+    orders = append(orders, node.order)
   }
+  sort.Ints(orders)
 
-  return false
+  for i, node := range nodes {
+    node.order = orders[i]
+  }
+  return true
 }
 
-func containsPkg(nodes []*Node, pkg string) bool {
-  for _, node := range nodes {
-    if node.Pkg == pkg {
-      return true
-    }
+// Adds a dependency like AddDependency, and records the line of the require
+// in the file of from. The line is used in cycle reports.
+func (g *DependencyGraph) AddRequire(from string, to string, line int) error {
+  if g.lines == nil {
+    g.lines = make(map[edge]int)
   }
 
-  return false
+  if _, ok := g.Nodes[from]; ok {
+    g.lines[edge{from, to}] = line
+  }
+  return g.AddDependency(from, to)
 }
 
+// Adds a dependency of the given kind, and records the line of its
+// declaration in the file of from. Strong dependencies are added with
+// AddRequire. Type-only and forward-declared dependencies do not affect the
+// load order and the contents of bundles, so they cannot close a cycle, and
+// their target does not have to be in the graph.
+func (g *DependencyGraph) AddEdge(from string, to string, kind EdgeKind,
+                                  line int) error {
+  if kind == StrongEdge || kind == "" {
+    return g.AddRequire(from, to, line)
+  }
 
-func (n *Node) isRecursivelyDependentOn(pkg string) bool {
-  return n.isRecursivelyDependentOnWithCache(pkg, make([]*Node, 0))
-}
+  if _, ok := g.Nodes[from]; !ok {
+    return errors.New("Package not found: " + from)
+  }
 
-func (n *Node) isRecursivelyDependentOnWithCache(pkg string,
-                                                 checked []*Node) bool {
-  if pkg == n.Pkg {
-    return true;
+  if g.weak == nil {
+    g.weak = make(map[edge]weakEdge)
   }
+  g.weak[edge{from, to}] = weakEdge{kind, line}
+  return nil
+}
 
-  if containsPkg(checked, pkg) {
-    return false;
+// Returns the namespaces that pkg depends on with the given kind of edge,
+// sorted.
+func (g *DependencyGraph) DependenciesOfKind(pkg string,
+                                             kind EdgeKind) []string {
+  deps := []string{}
+  if kind == StrongEdge || kind == "" {
+    if node, ok := g.Nodes[pkg]; ok {
+      for e := node.Dependencies.Front(); e != nil; e = e.Next() {
+        deps = append(deps, e.Value.(*Node).Pkg)
+      }
+    }
   }
 
-  for e := n.Dependencies.Front(); e != nil; e = e.Next() {
-    if e.Value.(*Node).isRecursivelyDependentOnWithCache(pkg, checked) {
-      return true
+  for e, w := range g.weak {
+    if e.from == pkg && w.kind == kind {
+      deps = append(deps, e.to)
     }
   }
+  sort.Strings(deps)
+  return deps
+}
+
+func (g *DependencyGraph) GetDependenciesOfPackage(pkg string) []*Node {
+  node, ok := g.Nodes[pkg]
+  if !ok {
+    return nil
+  }
 
-  checked = append(checked, n)
-  return false
+  return g.GetDependencies([]*Node{node})
+}
+
+// Returns the nodes and all their dependencies in RequireOrder. See
+// OrderedDependencies.
+func (g *DependencyGraph) GetDependencies(nodes []*Node) []*Node {
+  return g.OrderedDependencies(nodes, RequireOrder)
 }
 
+type Node struct {
+  Pkg string
+  Path string
+  // Nodes this node requires.
+  Dependencies *list.List
+  // Nodes requiring this node.
+  Dependents *list.List
+
+  dependencySet map[*Node]bool
+  // Position of the node in the topological order of the graph.
+  order int
+}
@@ -15,6 +15,9 @@
 package depgraph
 
 import (
+  "fmt"
+  "math/rand"
+  "strings"
   "testing"
 )
 
@@ -73,3 +76,179 @@ func TestDependecy(t *testing.T) {
   }
 }
 
+
+func TestConflicts(t *testing.T) {
+  graph := New()
+  graph.AddFile("pkg1", "file1")
+  graph.AddFile("pkg1", "file2")
+  graph.AddFile("pkg1", "file2")
+  graph.AddFile("pkg1", "file3")
+  graph.AddFile("pkg2", "file2")
+
+  if graph.Nodes["pkg1"].Path != "file1" {
+    t.Error("First provider is not kept: ", graph.Nodes["pkg1"].Path)
+  }
+
+  conflicts := graph.Conflicts()
+  if len(conflicts) != 1 || conflicts[0].Pkg != "pkg1" {
+    t.Fatal("Invalid conflicts: ", conflicts)
+  }
+
+  expected := []string{"file1", "file2", "file3"}
+  if len(conflicts[0].Paths) != len(expected) {
+    t.Fatal("Invalid conflicting files: ", conflicts[0].Paths)
+  }
+
+  for i, path := range conflicts[0].Paths {
+    if path != expected[i] {
+      t.Error("Invalid conflicting file: ", path, expected[i])
+    }
+  }
+}
+
+func TestTopologicalOrder(t *testing.T) {
+  r := rand.New(rand.NewSource(1))
+  graph := New()
+  for i := 0; i < 200; i++ {
+    graph.AddFile(fmt.Sprintf("pkg%d", i), fmt.Sprintf("pkg%d.js", i))
+  }
+
+  for i := 0; i < 1000; i++ {
+    from := fmt.Sprintf("pkg%d", r.Intn(200))
+    to := fmt.Sprintf("pkg%d", r.Intn(200))
+    reachable := graph.shortestPath(to, from) != nil
+    err := graph.AddDependency(from, to)
+    if reachable != (err != nil) {
+      t.Fatal("Invalid cycle check: ", from, "->", to, err)
+    }
+  }
+
+  nodes := graph.TopologicalOrder()
+  if len(nodes) != len(graph.Nodes) {
+    t.Fatal("Invalid number of nodes: ", len(nodes))
+  }
+
+  position := make(map[*Node]int)
+  for i, node := range nodes {
+    position[node] = i
+  }
+
+  for _, node := range nodes {
+    for e := node.Dependencies.Front(); e != nil; e = e.Next() {
+      if dep := e.Value.(*Node); position[dep] > position[node] {
+        t.Error("Dependency after its dependent: ", dep.Pkg, node.Pkg)
+      }
+    }
+  }
+}
+
+const benchmarkNodes = 50000
+
+// Creates a generated acyclic graph in which every package requires up to
+// three packages with a smaller number. Files are added in a shuffled order so
+// that requires do not follow the order of the files.
+func newBenchmarkGraph(b *testing.B, nodes int) DependencyGraph {
+  r := rand.New(rand.NewSource(1))
+  graph := New()
+  for _, i := range r.Perm(nodes) {
+    graph.AddFile(fmt.Sprintf("pkg%d", i), fmt.Sprintf("pkg%d.js", i))
+  }
+
+  for i := 1; i < nodes; i++ {
+    for j := 0; j < 3; j++ {
+      err := graph.AddDependency(fmt.Sprintf("pkg%d", i),
+                                 fmt.Sprintf("pkg%d", r.Intn(i)))
+      if err != nil {
+        b.Fatal(err)
+      }
+    }
+  }
+  return graph
+}
+
+func BenchmarkAddDependency(b *testing.B) {
+  for i := 0; i < b.N; i++ {
+    newBenchmarkGraph(b, benchmarkNodes)
+  }
+}
+
+func BenchmarkGetDependencies(b *testing.B) {
+  graph := newBenchmarkGraph(b, benchmarkNodes)
+  pkg := fmt.Sprintf("pkg%d", benchmarkNodes - 1)
+  b.ResetTimer()
+  for i := 0; i < b.N; i++ {
+    graph.GetDependenciesOfPackage(pkg)
+  }
+}
+
+func TestEdgeKinds(t *testing.T) {
+  graph := New()
+  graph.AddFile("a", "a.js")
+  graph.AddFile("b", "b.js")
+  graph.AddRequire("a", "b", 2)
+
+  if err := graph.AddEdge("b", "a", TypeEdge, 3); err != nil {
+    t.Error("Type-only dependency closes a cycle: ", err)
+  }
+
+  if err := graph.AddEdge("b", "missing", ForwardDeclareEdge, 4); err != nil {
+    t.Error("Cannot forward declare a missing package: ", err)
+  }
+
+  if err := graph.AddEdge("b", "a", StrongEdge, 5); err == nil {
+    t.Error("Strong dependency does not close a cycle.")
+  }
+
+  if deps := graph.GetDependenciesOfPackage("b"); len(deps) != 1 {
+    t.Error("Weak dependencies are resolved: ", deps)
+  }
+
+  if len(graph.Cycles()) != 1 {
+    t.Error("Invalid cycles: ", graph.Cycles())
+  }
+
+  kinds := [...]struct {
+    Kind EdgeKind
+    Deps string
+  }{
+    {StrongEdge, ""},
+    {TypeEdge, "a"},
+    {ForwardDeclareEdge, "missing"},
+  }
+
+  for _, k := range kinds {
+    deps := strings.Join(graph.DependenciesOfKind("b", k.Kind), " ")
+    if deps != k.Deps {
+      t.Error("Invalid dependencies of kind ", k.Kind, ": ", deps)
+    }
+  }
+}
+
+func TestClone(t *testing.T) {
+  graph := New()
+  graph.AddFile("a", "a.js")
+  graph.AddFile("b", "b.js")
+  graph.AddFile("b", "other/b.js")
+  graph.AddRequire("a", "b", 2)
+  graph.AddEdge("b", "a", TypeEdge, 3)
+
+  clone := graph.Clone()
+  if clone.Nodes["a"] == graph.Nodes["a"] ||
+     len(clone.GetDependenciesOfPackage("a")) != 2 ||
+     len(clone.Conflicts()) != 1 ||
+     clone.DependenciesOfKind("b", TypeEdge)[0] != "a" {
+    t.Fatal("Invalid clone: ", clone.Graph())
+  }
+
+  // Changes of the clone do not change the graph.
+  clone.AddFile("c", "c.js")
+  clone.AddRequire("b", "c", 4)
+  if _, ok := graph.Nodes["c"]; ok ||
+     len(graph.GetDependenciesOfPackage("a")) != 2 {
+    t.Error("Graph is changed with its clone: ", graph.Graph())
+  }
+
+  if len(clone.GetDependenciesOfPackage("a")) != 3 {
+    t.Error("Invalid dependencies of the clone: ", clone.Graph())
+  }
+}
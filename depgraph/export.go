@@ -0,0 +1,158 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depgraph
+
+import (
+  "bufio"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io"
+  "sort"
+)
+
+// Graph is the JSON form of a dependency graph:
+//
+//   {
+//     "nodes": [{"namespace": "app", "file": "app.js"}, ...],
+//     "edges": [{"from": "app", "to": "goog.dom", "kind": "require"}, ...]
+//   }
+//
+// Nodes are sorted by namespace, and edges by their endpoints and kind. An
+// edge from A to B means A requires B; its kind tells how (see EdgeKind).
+type Graph struct {
+  Nodes []GraphNode `json:"nodes"`
+  Edges []GraphEdge `json:"edges"`
+}
+
+type GraphNode struct {
+  Namespace string `json:"namespace"`
+  File string `json:"file"`
+}
+
+type GraphEdge struct {
+  From string `json:"from"`
+  To string `json:"to"`
+  Kind EdgeKind `json:"kind"`
+}
+
+// Returns the subgraph of the nodes reachable from the given packages. Every
+// namespace provided by a file in the subgraph is in the subgraph, with its
+// dependencies.
+func (g *DependencyGraph) Subgraph(pkgs ...string) (DependencyGraph, error) {
+  stack := []*Node{}
+  for _, pkg := range pkgs {
+    node, ok := g.Nodes[pkg]
+    if !ok {
+      return DependencyGraph{}, errors.New("Package not found: " + pkg)
+    }
+    stack = append(stack, node)
+  }
+
+  files := make(map[string][]*Node)
+  for _, node := range g.Nodes {
+    files[node.Path] = append(files[node.Path], node)
+  }
+
+  sub := New()
+  for len(stack) != 0 {
+    node := stack[len(stack) - 1]
+    stack = stack[:len(stack) - 1]
+    if _, ok := sub.Nodes[node.Pkg]; ok {
+      continue
+    }
+
+    sub.Nodes[node.Pkg] = node
+    stack = append(stack, files[node.Path]...)
+    for e := node.Dependencies.Front(); e != nil; e = e.Next() {
+      stack = append(stack, e.Value.(*Node))
+    }
+  }
+
+  for e, w := range g.weak {
+    if _, ok := sub.Nodes[e.from]; ok {
+      sub.AddEdge(e.from, e.to, w.kind, w.line)
+    }
+  }
+  return sub, nil
+}
+
+// Returns the JSON form of the graph.
+func (g *DependencyGraph) Graph() Graph {
+  graph := Graph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+  for pkg, node := range g.Nodes {
+    graph.Nodes = append(graph.Nodes, GraphNode{pkg, node.Path})
+    for e := node.Dependencies.Front(); e != nil; e = e.Next() {
+      dep := e.Value.(*Node)
+      if _, ok := g.Nodes[dep.Pkg]; ok {
+        graph.Edges = append(graph.Edges,
+                             GraphEdge{pkg, dep.Pkg, StrongEdge})
+      }
+    }
+  }
+
+  for e, w := range g.weak {
+    if _, ok := g.Nodes[e.to]; ok {
+      graph.Edges = append(graph.Edges, GraphEdge{e.from, e.to, w.kind})
+    }
+  }
+
+  sort.Slice(graph.Nodes, func(i, j int) bool {
+    return graph.Nodes[i].Namespace < graph.Nodes[j].Namespace
+  })
+  sort.Slice(graph.Edges, func(i, j int) bool {
+    a, b := graph.Edges[i], graph.Edges[j]
+    if a.From != b.From {
+      return a.From < b.From
+    }
+
+    if a.To != b.To {
+      return a.To < b.To
+    }
+    return a.Kind < b.Kind
+  })
+  return graph
+}
+
+// Writes the graph as JSON.
+func (g *DependencyGraph) WriteJson(w io.Writer) error {
+  enc := json.NewEncoder(w)
+  enc.SetIndent("", "  ")
+  return enc.Encode(g.Graph())
+}
+
+// Writes the graph in Graphviz DOT. Nodes are labeled by their namespace and
+// file. Type-only and forward-declared dependencies are dashed.
+func (g *DependencyGraph) WriteDot(w io.Writer) error {
+  graph := g.Graph()
+  bw := bufio.NewWriter(w)
+  fmt.Fprintln(bw, "digraph deps {")
+  fmt.Fprintln(bw, "  node [shape=box];")
+  for _, n := range graph.Nodes {
+    fmt.Fprintf(bw, "  %q [label=%q];\n", n.Namespace,
+                n.Namespace + "\n" + n.File)
+  }
+
+  for _, e := range graph.Edges {
+    if e.Kind == StrongEdge {
+      fmt.Fprintf(bw, "  %q -> %q;\n", e.From, e.To)
+    } else {
+      fmt.Fprintf(bw, "  %q -> %q [style=dashed, label=%q];\n", e.From, e.To,
+                  e.Kind)
+    }
+  }
+  fmt.Fprintln(bw, "}")
+  return bw.Flush()
+}
@@ -0,0 +1,169 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depgraph
+
+import (
+  "bytes"
+  "encoding/json"
+  "testing"
+)
+
+// Creates a graph with two entry points: app, and admin referencing the types
+// of app.
+func newExportGraph() DependencyGraph {
+  graph := New()
+  graph.AddFile("app", "app.js")
+  graph.AddFile("goog.dom", "closure/goog/dom.js")
+  graph.AddFile("goog.array", "closure/goog/array.js")
+  graph.AddFile("admin", "admin.js")
+  graph.AddDependency("app", "goog.dom")
+  graph.AddDependency("goog.dom", "goog.array")
+  graph.AddDependency("admin", "goog.array")
+  graph.AddEdge("admin", "app", TypeEdge, 3)
+  return graph
+}
+
+func TestWriteJson(t *testing.T) {
+  graph := newExportGraph()
+  var buf bytes.Buffer
+  if err := graph.WriteJson(&buf); err != nil {
+    t.Fatal(err)
+  }
+
+  var decoded Graph
+  if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+    t.Fatal(err)
+  }
+
+  if len(decoded.Nodes) != 4 || decoded.Nodes[0].Namespace != "admin" {
+    t.Error("Invalid nodes: ", decoded.Nodes)
+  }
+
+  expected := []GraphEdge{
+    {"admin", "app", TypeEdge},
+    {"admin", "goog.array", StrongEdge},
+    {"app", "goog.dom", StrongEdge},
+    {"goog.dom", "goog.array", StrongEdge},
+  }
+  if len(decoded.Edges) != len(expected) {
+    t.Fatal("Invalid edges: ", decoded.Edges)
+  }
+
+  for i, e := range decoded.Edges {
+    if e != expected[i] {
+      t.Error("Invalid edge: ", e, expected[i])
+    }
+  }
+}
+
+func TestSubgraph(t *testing.T) {
+  graph := newExportGraph()
+  sub, err := graph.Subgraph("app")
+  if err != nil || len(sub.Nodes) != 3 {
+    t.Fatal("Invalid subgraph: ", sub.Nodes, err)
+  }
+
+  if _, ok := sub.Nodes["admin"]; ok {
+    t.Error("Unreachable package in the subgraph.")
+  }
+
+  // Type-only dependencies do not pull packages into the subgraph.
+  sub, err = graph.Subgraph("admin")
+  if err != nil || len(sub.Nodes) != 2 || len(sub.Graph().Edges) != 1 {
+    t.Error("Invalid subgraph: ", sub.Graph(), err)
+  }
+
+  if _, err = graph.Subgraph("missing"); err == nil {
+    t.Error("Subgraph of a missing package.")
+  }
+}
+
+func TestSubgraphOfFileWithManyPackages(t *testing.T) {
+  graph := New()
+  graph.AddFile("app", "app.js")
+  graph.AddFile("goog.dom", "dom.js")
+  graph.AddFile("goog.dom.TagName", "dom.js")
+  graph.AddFile("goog.array", "array.js")
+  graph.AddFile("goog.string", "string.js")
+  graph.AddDependency("app", "goog.dom")
+  graph.AddDependency("app", "goog.dom.TagName")
+  graph.AddDependency("goog.dom", "goog.array")
+  graph.AddDependency("goog.dom.TagName", "goog.string")
+
+  sub, err := graph.Subgraph("app")
+  if err != nil || len(sub.Nodes) != 5 {
+    t.Fatal("Invalid subgraph: ", sub.Nodes, err)
+  }
+
+  if edges := sub.Graph().Edges; len(edges) != 4 ||
+     edges[1] != (GraphEdge{"app", "goog.dom.TagName", StrongEdge}) {
+    t.Error("Invalid edges: ", edges)
+  }
+
+  // Packages of a file in the subgraph are included even if not required.
+  sub, _ = graph.Subgraph("goog.dom")
+  if _, ok := sub.Nodes["goog.string"]; !ok || len(sub.Nodes) != 4 {
+    t.Error("Invalid subgraph: ", sub.Nodes)
+  }
+}
+
+func TestGraphEdgeOrder(t *testing.T) {
+  graph := New()
+  graph.AddFile("a", "a.js")
+  graph.AddFile("b", "b.js")
+  graph.AddRequire("a", "b", 2)
+  graph.AddEdge("a", "b", TypeEdge, 3)
+
+  // Edges between the same packages are sorted by kind, whatever the order
+  // in which they are collected.
+  for i := 0; i < 10; i++ {
+    edges := graph.Graph().Edges
+    if len(edges) != 2 || edges[0].Kind != StrongEdge ||
+       edges[1].Kind != TypeEdge {
+      t.Fatal("Invalid edges: ", edges)
+    }
+  }
+}
+
+func TestWriteDot(t *testing.T) {
+  graph := New()
+  graph.AddFile("goog.dom", "closure/goog/dom.js")
+  graph.AddFile("goog.array", "closure/goog/array.js")
+  graph.AddFile("app", "app.js")
+  graph.AddFile("admin", "admin.js")
+  graph.AddDependency("goog.dom", "goog.array")
+  graph.AddEdge("admin", "app", TypeEdge, 3)
+  sub, _ := graph.Subgraph("goog.dom")
+
+  var buf bytes.Buffer
+  sub.WriteDot(&buf)
+  expected := `digraph deps {
+  node [shape=box];
+  "goog.array" [label="goog.array\nclosure/goog/array.js"];
+  "goog.dom" [label="goog.dom\nclosure/goog/dom.js"];
+  "goog.dom" -> "goog.array";
+}
+`
+  if buf.String() != expected {
+    t.Error("Invalid DOT: ", buf.String())
+  }
+
+  buf.Reset()
+  graph.WriteDot(&buf)
+  if !bytes.Contains(buf.Bytes(), []byte(
+      `"admin" -> "app" [style=dashed, label="requireType"];`)) {
+    t.Error("Invalid DOT of a type-only dependency: ", buf.String())
+  }
+}
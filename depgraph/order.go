@@ -0,0 +1,146 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depgraph
+
+import (
+  "container/heap"
+  "sort"
+)
+
+// Order is the rule ordering the dependencies of a package that do not depend
+// on one another.
+type Order string
+
+const (
+  // Dependencies are placed in the order of the requires of their dependent.
+  RequireOrder Order = "require"
+  // Dependencies are placed in the lexicographic order of their namespaces.
+  NamespaceOrder Order = "namespace"
+)
+
+// Returns the dependencies of the node in the given order.
+func (n *Node) orderedDependencies(order Order) []*Node {
+  deps := make([]*Node, 0, n.Dependencies.Len())
+  for e := n.Dependencies.Front(); e != nil; e = e.Next() {
+    deps = append(deps, e.Value.(*Node))
+  }
+
+  if order == NamespaceOrder {
+    sort.Slice(deps, func(i, j int) bool {
+      return deps[i].Pkg < deps[j].Pkg
+    })
+  }
+  return deps
+}
+
+// Returns the nodes and all their dependencies, every dependency before its
+// dependents. Only the first node of every file is returned.
+//
+// The result is the same for the same graph: the nodes are walked depth-first
+// in the given order, and the dependencies of every node are walked in order
+// before the node itself (e.g., with RequireOrder, a file requiring b and then
+// a gets the dependencies of b, b, the dependencies of a, and a).
+func (g *DependencyGraph) OrderedDependencies(nodes []*Node,
+                                              order Order) []*Node {
+  type frame struct {
+    node *Node
+    deps []*Node
+  }
+
+  deps := []*Node{}
+  paths := make(map[string]bool)
+  visited := make(map[*Node]bool)
+  for _, node := range nodes {
+    if paths[node.Path] {
+      continue
+    }
+
+    visited[node] = true
+    stack := []frame{{node, node.orderedDependencies(order)}}
+    for len(stack) != 0 {
+      top := &stack[len(stack) - 1]
+      if len(top.deps) == 0 {
+        stack = stack[:len(stack) - 1]
+        if !paths[top.node.Path] {
+          paths[top.node.Path] = true
+          deps = append(deps, top.node)
+        }
+        continue
+      }
+
+      dep := top.deps[0]
+      top.deps = top.deps[1:]
+      if !paths[dep.Path] && !visited[dep] {
+        visited[dep] = true
+        stack = append(stack, frame{dep, dep.orderedDependencies(order)})
+      }
+    }
+  }
+  return deps
+}
+
+// A min-heap of nodes by namespace.
+type nodeHeap []*Node
+
+func (h nodeHeap) Len() int { return len(h) }
+func (h nodeHeap) Less(i, j int) bool { return h[i].Pkg < h[j].Pkg }
+func (h nodeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *nodeHeap) Push(x interface{}) { *h = append(*h, x.(*Node)) }
+
+func (h *nodeHeap) Pop() interface{} {
+  old := *h
+  node := old[len(old) - 1]
+  *h = old[:len(old) - 1]
+  return node
+}
+
+// Returns all nodes in a topological order: every node after its
+// dependencies. Among the nodes whose dependencies are all placed, the one
+// with the smallest namespace comes first, so the order depends only on the
+// packages and their requires.
+func (g *DependencyGraph) TopologicalOrder() []*Node {
+  pending := make(map[*Node]int)
+  ready := &nodeHeap{}
+  for _, node := range g.Nodes {
+    for e := node.Dependencies.Front(); e != nil; e = e.Next() {
+      if _, ok := g.Nodes[e.Value.(*Node).Pkg]; ok {
+        pending[node]++
+      }
+    }
+
+    if pending[node] == 0 {
+      *ready = append(*ready, node)
+    }
+  }
+  heap.Init(ready)
+
+  nodes := make([]*Node, 0, len(g.Nodes))
+  for ready.Len() != 0 {
+    node := heap.Pop(ready).(*Node)
+    nodes = append(nodes, node)
+    for e := node.Dependents.Front(); e != nil; e = e.Next() {
+      dep := e.Value.(*Node)
+      if _, ok := g.Nodes[dep.Pkg]; !ok {
+        continue
+      }
+
+      pending[dep]--
+      if pending[dep] == 0 {
+        heap.Push(ready, dep)
+      }
+    }
+  }
+  return nodes
+}
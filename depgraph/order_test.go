@@ -0,0 +1,72 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depgraph
+
+import (
+  "strings"
+  "testing"
+)
+
+func pkgsOf(nodes []*Node) string {
+  pkgs := []string{}
+  for _, node := range nodes {
+    pkgs = append(pkgs, node.Pkg)
+  }
+  return strings.Join(pkgs, " ")
+}
+
+func TestOrderedDependencies(t *testing.T) {
+  g := New()
+  for _, pkg := range []string{"app", "b", "a", "c", "d"} {
+    g.AddFile(pkg, pkg + ".js")
+  }
+  g.AddDependency("app", "b")
+  g.AddDependency("app", "a")
+  g.AddDependency("b", "d")
+  g.AddDependency("b", "c")
+  g.AddDependency("a", "c")
+
+  nodes := []*Node{g.Nodes["app"]}
+  orders := [...]struct {
+    Order Order
+    Pkgs string
+  }{
+    {RequireOrder, "d c b a app"},
+    {NamespaceOrder, "c a d b app"},
+  }
+
+  for _, o := range orders {
+    for i := 0; i < 10; i++ {
+      pkgs := pkgsOf(g.OrderedDependencies(nodes, o.Order))
+      if pkgs != o.Pkgs {
+        t.Error("Invalid order: ", o.Order, pkgs)
+      }
+    }
+  }
+}
+
+func TestTopologicalOrderTieBreak(t *testing.T) {
+  g := New()
+  for _, pkg := range []string{"app", "b", "c", "a"} {
+    g.AddFile(pkg, pkg + ".js")
+  }
+  g.AddDependency("app", "b")
+  g.AddDependency("app", "a")
+  g.AddDependency("b", "c")
+
+  if pkgs := pkgsOf(g.TopologicalOrder()); pkgs != "a c b app" {
+    t.Error("Invalid topological order: ", pkgs)
+  }
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depgraph
+
+import (
+  "errors"
+  "fmt"
+  "strings"
+)
+
+// RequirePath is a chain of requires from one package to another: every step
+// requires the next one. The line of the last step is 0.
+type RequirePath struct {
+  Steps []CycleStep `json:"steps"`
+}
+
+// Formats the path as "app (app.js:3) -> app.ui (ui.js:7) -> lib (lib.js)".
+func (p RequirePath) String() string {
+  parts := make([]string, 0, len(p.Steps))
+  for _, step := range p.Steps {
+    pos := step.Path
+    if step.Line != 0 {
+      pos = fmt.Sprintf("%s:%d", step.Path, step.Line)
+    }
+    parts = append(parts, fmt.Sprintf("%s (%s)", step.Pkg, pos))
+  }
+  return strings.Join(parts, " -> ")
+}
+
+func (g *DependencyGraph) requirePath(nodes []*Node) RequirePath {
+  steps := make([]CycleStep, 0, len(nodes))
+  for i, node := range nodes {
+    line := 0
+    if i < len(nodes) - 1 {
+      line = g.lines[edge{node.Pkg, nodes[i + 1].Pkg}]
+    }
+    steps = append(steps, CycleStep{node.Pkg, node.Path, line})
+  }
+  return RequirePath{steps}
+}
+
+func (g *DependencyGraph) checkPackages(pkgs ...string) error {
+  for _, pkg := range pkgs {
+    if _, ok := g.Nodes[pkg]; !ok {
+      return errors.New("Package not found: " + pkg)
+    }
+  }
+  return nil
+}
+
+// Returns the shortest chain of requires from one package to another. ok is
+// false if from does not depend on to.
+func (g *DependencyGraph) ShortestPath(from string, to string) (
+    path RequirePath, ok bool, err error) {
+  if err = g.checkPackages(from, to); err != nil {
+    return
+  }
+
+  nodes := g.shortestPath(from, to)
+  if nodes == nil {
+    return
+  }
+  return g.requirePath(nodes), true, nil
+}
+
+// Returns the chains of requires from one package to another, shortest first,
+// with ties in the order of the requires. At most limit paths are returned, or
+// all of them if limit is 0. Paths are found one at a time with Yen's
+// algorithm, so the work grows with limit rather than with the number of
+// paths in the graph.
+func (g *DependencyGraph) AllPaths(from string, to string,
+                                   limit int) ([]RequirePath, error) {
+  if err := g.checkPackages(from, to); err != nil {
+    return nil, err
+  }
+
+  // Nodes of the root of a path are not reused by its spur, and the spur
+  // node does not take the requires taken by the paths already found.
+  onRoot := make(map[*Node]bool)
+  taken := make(map[*Node]bool)
+  var spurNode *Node
+  blocked := func(from *Node, dep *Node) bool {
+    if _, ok := g.Nodes[dep.Pkg]; !ok {
+      return true
+    }
+    return onRoot[dep] || (from == spurNode && taken[dep])
+  }
+
+  first := shortestPathAvoiding(g.Nodes[from], to, blocked)
+  if first == nil {
+    return []RequirePath{}, nil
+  }
+
+  found := [][]*Node{first}
+  candidates := [][]*Node{}
+  seen := map[string]bool{pathKey(first): true}
+  for limit == 0 || len(found) < limit {
+    last := found[len(found) - 1]
+    for i := 0; i < len(last) - 1; i++ {
+      root := last[:i + 1]
+      spurNode = last[i]
+      onRoot = make(map[*Node]bool)
+      for _, node := range root[:i] {
+        onRoot[node] = true
+      }
+
+      taken = make(map[*Node]bool)
+      for _, p := range found {
+        if len(p) > i + 1 && pathKey(p[:i + 1]) == pathKey(root) {
+          taken[p[i + 1]] = true
+        }
+      }
+
+      spur := shortestPathAvoiding(spurNode, to, blocked)
+      if spur == nil {
+        continue
+      }
+
+      path := append(append([]*Node{}, root[:i]...), spur...)
+      if key := pathKey(path); !seen[key] {
+        seen[key] = true
+        candidates = append(candidates, path)
+      }
+    }
+
+    if len(candidates) == 0 {
+      break
+    }
+
+    best := 0
+    for i, c := range candidates {
+      if len(c) < len(candidates[best]) {
+        best = i
+      }
+    }
+    found = append(found, candidates[best])
+    candidates = append(candidates[:best], candidates[best + 1:]...)
+  }
+
+  paths := make([]RequirePath, 0, len(found))
+  for _, nodes := range found {
+    paths = append(paths, g.requirePath(nodes))
+  }
+  return paths, nil
+}
+
+func pathKey(nodes []*Node) string {
+  pkgs := make([]string, 0, len(nodes))
+  for _, node := range nodes {
+    pkgs = append(pkgs, node.Pkg)
+  }
+  return strings.Join(pkgs, "\x00")
+}
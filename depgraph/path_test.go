@@ -0,0 +1,124 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depgraph
+
+import (
+  "fmt"
+  "testing"
+)
+
+func TestShortestPath(t *testing.T) {
+  g := New()
+  for _, pkg := range []string{"app.ui", "app.util", "lib"} {
+    g.AddFile(pkg, pkg + ".js")
+  }
+  g.AddRequire("app.ui", "lib", 2)
+  g.AddRequire("app.ui", "app.util", 4)
+  g.AddRequire("app.util", "lib", 5)
+
+  path, ok, err := g.ShortestPath("app.ui", "lib")
+  expected := "app.ui (app.ui.js:2) -> lib (lib.js)"
+  if !ok || err != nil || path.String() != expected {
+    t.Error("Invalid path: ", path, ok, err)
+  }
+
+  if _, ok, err = g.ShortestPath("lib", "app.ui"); ok || err != nil {
+    t.Error("Path against the requires: ", ok, err)
+  }
+
+  if _, _, err = g.ShortestPath("app.ui", "missing"); err == nil {
+    t.Error("No error for a missing package.")
+  }
+}
+
+func TestAllPaths(t *testing.T) {
+  g := New()
+  for _, pkg := range []string{"app", "app.ui", "app.util", "lib"} {
+    g.AddFile(pkg, pkg + ".js")
+  }
+  g.AddRequire("app", "app.util", 2)
+  g.AddRequire("app", "app.ui", 3)
+  g.AddRequire("app.ui", "app.util", 4)
+  g.AddRequire("app.util", "lib", 5)
+
+  paths, err := g.AllPaths("app", "lib", 0)
+  expected := []string{
+    "app (app.js:2) -> app.util (app.util.js:5) -> lib (lib.js)",
+    "app (app.js:3) -> app.ui (app.ui.js:4) -> app.util (app.util.js:5) -> " +
+        "lib (lib.js)",
+  }
+  if err != nil || len(paths) != len(expected) {
+    t.Fatal("Invalid paths: ", paths, err)
+  }
+
+  for i, path := range paths {
+    if path.String() != expected[i] {
+      t.Error("Invalid path: ", path.String(), expected[i])
+    }
+  }
+
+  paths, err = g.AllPaths("app", "lib", 1)
+  if err != nil || len(paths) != 1 {
+    t.Error("Paths are not limited: ", paths, err)
+  }
+}
+
+func TestAllPathsShortestFirst(t *testing.T) {
+  g := New()
+  for _, pkg := range []string{"app", "a", "b", "lib"} {
+    g.AddFile(pkg, pkg + ".js")
+  }
+
+  // The longer chain is required first.
+  g.AddRequire("app", "a", 1)
+  g.AddRequire("a", "b", 1)
+  g.AddRequire("b", "lib", 1)
+  g.AddRequire("app", "lib", 2)
+
+  paths, err := g.AllPaths("app", "lib", 1)
+  if err != nil || len(paths) != 1 || len(paths[0].Steps) != 2 {
+    t.Error("Shortest path is not first: ", paths, err)
+  }
+}
+
+func TestAllPathsOfDiamonds(t *testing.T) {
+  // A chain of 40 diamonds has 2^40 paths from its top to its bottom.
+  g := New()
+  g.AddFile("d0", "d0.js")
+  for i := 0; i < 40; i++ {
+    top := fmt.Sprintf("d%d", i)
+    bottom := fmt.Sprintf("d%d", i + 1)
+    g.AddFile(bottom, bottom + ".js")
+    for _, side := range []string{"l", "r"} {
+      pkg := top + side
+      g.AddFile(pkg, pkg + ".js")
+      g.AddRequire(top, pkg, 1)
+      g.AddRequire(pkg, bottom, 1)
+    }
+  }
+
+  paths, err := g.AllPaths("d0", "d40", 10)
+  if err != nil || len(paths) != 10 {
+    t.Fatal("Invalid paths: ", len(paths), err)
+  }
+
+  seen := make(map[string]bool)
+  for _, path := range paths {
+    if len(path.Steps) != 81 || seen[path.String()] {
+      t.Error("Invalid path: ", path)
+    }
+    seen[path.String()] = true
+  }
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depgraph
+
+import (
+  "errors"
+  "sort"
+)
+
+// Returns the packages directly requiring pkg, sorted.
+func (g *DependencyGraph) Dependents(pkg string) ([]string, error) {
+  node, ok := g.Nodes[pkg]
+  if !ok {
+    return nil, errors.New("Package not found: " + pkg)
+  }
+
+  deps := []string{}
+  for e := node.Dependents.Front(); e != nil; e = e.Next() {
+    dep := e.Value.(*Node)
+    if _, ok := g.Nodes[dep.Pkg]; ok {
+      deps = append(deps, dep.Pkg)
+    }
+  }
+  sort.Strings(deps)
+  return deps, nil
+}
+
+// Returns the packages requiring any of pkgs directly or transitively, sorted.
+// pkgs themselves are not included unless they require one another.
+func (g *DependencyGraph) TransitiveDependents(pkgs ...string) ([]string,
+                                                               error) {
+  queue := []*Node{}
+  for _, pkg := range pkgs {
+    node, ok := g.Nodes[pkg]
+    if !ok {
+      return nil, errors.New("Package not found: " + pkg)
+    }
+    queue = append(queue, node)
+  }
+
+  seen := make(map[string]bool)
+  deps := []string{}
+  for len(queue) != 0 {
+    node := queue[0]
+    queue = queue[1:]
+    for e := node.Dependents.Front(); e != nil; e = e.Next() {
+      dep := e.Value.(*Node)
+      if _, ok := g.Nodes[dep.Pkg]; !ok || seen[dep.Pkg] {
+        continue
+      }
+
+      seen[dep.Pkg] = true
+      deps = append(deps, dep.Pkg)
+      queue = append(queue, dep)
+    }
+  }
+  sort.Strings(deps)
+  return deps, nil
+}
+
+// Returns the packages that no other package requires, sorted. These are the
+// entry points of the graph.
+func (g *DependencyGraph) Roots() []string {
+  roots := []string{}
+  for pkg := range g.Nodes {
+    if deps, _ := g.Dependents(pkg); len(deps) == 0 {
+      roots = append(roots, pkg)
+    }
+  }
+  sort.Strings(roots)
+  return roots
+}
+
+// Returns the roots that are any of pkgs or require them transitively, sorted.
+// These are the entry points to rebuild when pkgs change.
+func (g *DependencyGraph) AffectedRoots(pkgs ...string) ([]string, error) {
+  deps, err := g.TransitiveDependents(pkgs...)
+  if err != nil {
+    return nil, err
+  }
+
+  affected := []string{}
+  seen := make(map[string]bool)
+  for _, pkg := range append(deps, pkgs...) {
+    if seen[pkg] {
+      continue
+    }
+    seen[pkg] = true
+
+    if dependents, _ := g.Dependents(pkg); len(dependents) == 0 {
+      affected = append(affected, pkg)
+    }
+  }
+  sort.Strings(affected)
+  return affected, nil
+}
+
+// Returns the packages provided by the file at path, sorted.
+func (g *DependencyGraph) PackagesOfFile(path string) []string {
+  pkgs := []string{}
+  for pkg, node := range g.Nodes {
+    if node.Path == path {
+      pkgs = append(pkgs, pkg)
+    }
+  }
+  sort.Strings(pkgs)
+  return pkgs
+}
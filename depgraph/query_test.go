@@ -0,0 +1,74 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depgraph
+
+import (
+  "reflect"
+  "testing"
+)
+
+func TestDependents(t *testing.T) {
+  g := New()
+  g.AddFile("app", "app.js")
+  g.AddFile("app.ui", "ui.js")
+  g.AddFile("app.util.strings", "util.js")
+  g.AddFile("goog.dom", "dom.js")
+  g.AddDependency("app", "app.ui")
+  g.AddDependency("app.ui", "app.util.strings")
+  g.AddDependency("app.ui", "goog.dom")
+
+  deps, err := g.Dependents("app.util.strings")
+  if err != nil || !reflect.DeepEqual(deps, []string{"app.ui"}) {
+    t.Error("Invalid dependents: ", deps, err)
+  }
+
+  deps, err = g.TransitiveDependents("app.util.strings", "goog.dom")
+  if err != nil || !reflect.DeepEqual(deps, []string{"app", "app.ui"}) {
+    t.Error("Invalid transitive dependents: ", deps, err)
+  }
+
+  if _, err = g.Dependents("app.missing"); err == nil {
+    t.Error("No error for a missing package.")
+  }
+}
+
+func TestRoots(t *testing.T) {
+  // Two entry points, app and admin, sharing util.js.
+  g := New()
+  g.AddFile("app", "app.js")
+  g.AddFile("admin", "admin.js")
+  g.AddFile("app.util", "util.js")
+  g.AddFile("app.util.strings", "util.js")
+  g.AddDependency("app", "app.util.strings")
+  g.AddDependency("admin", "app.util")
+
+  if roots := g.Roots(); !reflect.DeepEqual(roots, []string{"admin", "app"}) {
+    t.Error("Invalid roots: ", roots)
+  }
+
+  affected, err := g.AffectedRoots(g.PackagesOfFile("util.js")...)
+  if err != nil || !reflect.DeepEqual(affected, []string{"admin", "app"}) {
+    t.Error("Invalid affected roots: ", affected, err)
+  }
+
+  affected, err = g.AffectedRoots("app")
+  if err != nil || !reflect.DeepEqual(affected, []string{"app"}) {
+    t.Error("Invalid affected roots of a root: ", affected, err)
+  }
+
+  if _, err = g.AffectedRoots("app.missing"); err == nil {
+    t.Error("No error for a missing package.")
+  }
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "io/fs"
+  "path"
+  "regexp"
+  "strings"
+
+  "github.com/golang/glog"
+  "github.com/soheilhy/glosure/depgraph"
+)
+
+// Matches the path, provides, requires and load flags of a goog.addDependency
+// call (e.g., "goog.addDependency('dom/dom.js', ['goog.dom'], ['goog.array'],
+// {'module': 'goog'});").
+var addDependencyRegex = regexp.MustCompile(
+    `goog\.addDependency\(\s*['"]([^'"]+)['"]\s*,\s*\[([^\]]*)\]\s*,` +
+    `\s*\[([^\]]*)\]\s*(?:,\s*([^)]*))?\)`)
+
+// Matches the module kind in the load flags of a goog.addDependency call.
+var depsModuleRegex = regexp.MustCompile(`['"]?module['"]?\s*:\s*['"](\w+)`)
+
+var quotedNameRegex = regexp.MustCompile(`['"]([^'"]+)['"]`)
+
+// A file listed in a deps file.
+type depsEntry struct {
+  scanResult
+  // Path of the file as listed in the deps file, or its name in the source
+  // tree once resolved.
+  name string
+}
+
+func quotedNames(list string) []string {
+  names := []string{}
+  for _, m := range quotedNameRegex.FindAllStringSubmatch(list, -1) {
+    names = append(names, m[1])
+  }
+  return names
+}
+
+// Parses the goog.addDependency calls of a deps file.
+func parseDepsFile(src string) []depsEntry {
+  entries := []depsEntry{}
+  for _, m := range addDependencyRegex.FindAllStringSubmatch(src, -1) {
+    entry := depsEntry{name: m[1]}
+    entry.Provides = quotedNames(m[2])
+    entry.Module = scriptModule
+    if len(entry.Provides) != 0 {
+      entry.Module = provideModule
+    }
+
+    // Old deps files mark goog.modules with a boolean instead of load flags.
+    flags := strings.TrimSpace(m[4])
+    if f := depsModuleRegex.FindStringSubmatch(flags); f != nil {
+      switch f[1] {
+      case "goog":
+        entry.Module = googModule
+      case "es6":
+        entry.Module = es6Module
+      }
+    } else if flags == "true" {
+      entry.Module = googModule
+    }
+
+    for _, ns := range quotedNames(m[3]) {
+      entry.Requires = append(entry.Requires,
+                              closureRequire{ns, 0, depgraph.StrongEdge})
+    }
+    entries = append(entries, entry)
+  }
+  return entries
+}
+
+// Returns the files listed in the deps files of the root, named by the prefix
+// of the root. Files outside the root are skipped.
+func readDepsFiles(root SourceRoot) []depsEntry {
+  entries := []depsEntry{}
+  for _, depsFile := range root.DepsFiles {
+    content, err := fs.ReadFile(root.Sources, depsFile)
+    if err != nil {
+      glog.Warning("Cannot read deps file ", depsFile, " in root ", root.Name,
+                   ": ", err)
+      continue
+    }
+
+    base := root.DepsBase
+    if base == "" {
+      base = path.Dir(depsFile)
+    }
+
+    for _, entry := range parseDepsFile(string(content)) {
+      name := path.Join(base, entry.name)
+      if name == ".." || strings.HasPrefix(name, "../") {
+        glog.Warning("Skipping ", entry.name, " in ", depsFile,
+                     ": outside of root ", root.Name)
+        continue
+      }
+
+      entry.name = root.Prefix + name
+      entries = append(entries, entry)
+    }
+  }
+  return entries
+}
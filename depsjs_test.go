@@ -0,0 +1,125 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "io/ioutil"
+  "path/filepath"
+  "strings"
+  "testing"
+  "testing/fstest"
+)
+
+func TestParseDepsFile(t *testing.T) {
+  src := `// This file was autogenerated.
+goog.addDependency('dom/dom.js', ['goog.dom', 'goog.dom.TagName'],
+                   ['goog.array'], false);
+goog.addDependency("array/array.js", ["goog.array"], [],
+                   {'lang': 'es6', 'module': 'goog'});
+`
+  entries := parseDepsFile(src)
+  if len(entries) != 2 || entries[0].name != "dom/dom.js" ||
+     strings.Join(entries[0].Provides, " ") != "goog.dom goog.dom.TagName" ||
+     len(entries[0].Requires) != 1 ||
+     entries[0].Requires[0].Namespace != "goog.array" ||
+     entries[1].name != "array/array.js" || len(entries[1].Requires) != 0 {
+    t.Error("Invalid deps entries: ", entries)
+  }
+
+  if entries[0].Module != provideModule || entries[1].Module != googModule {
+    t.Error("Invalid module kinds: ", entries[0].Module, entries[1].Module)
+  }
+}
+
+func newDepsCompiler() *Compiler {
+  cc := NewCompilerWithSources(fstest.MapFS{
+    "app.js": {Data: []byte("goog.provide('app');\ngoog.require('goog.dom');")},
+  })
+  cc.Roots = []SourceRoot{{
+    Name: "closure-library",
+    Sources: fstest.MapFS{
+      "goog/deps.js": {Data: []byte(
+          "goog.addDependency('dom/dom.js', ['goog.dom'], ['goog.array']);\n" +
+          "goog.addDependency('array/array.js', ['goog.array'], []);\n" +
+          "goog.addDependency('../../outside.js', ['outside'], []);\n")},
+      // The deps file is trusted over the sources.
+      "goog/dom/dom.js": {Data: []byte("goog.provide('goog.dom.scanned');")},
+      "goog/array/array.js": {Data: []byte("goog.provide('goog.array');")},
+      "goog/unlisted.js": {Data: []byte("goog.provide('goog.unlisted');")},
+    },
+    Prefix: "closure",
+    DepsFiles: []string{"goog/deps.js"},
+  }}
+  return cc
+}
+
+func TestDepsFiles(t *testing.T) {
+  cc := newDepsCompiler()
+  g := cc.DependencyGraph()
+  deps := g.GetDependenciesOfPackage("app")
+  expected := []string{"closure/goog/array/array.js", "closure/goog/dom/dom.js",
+                       "app.js"}
+  if len(deps) != len(expected) {
+    t.Fatal("Invalid dependencies: ", deps)
+  }
+
+  for i, dep := range deps {
+    if dep.Path != expected[i] {
+      t.Error("Invalid dependency: ", dep.Path, expected[i])
+    }
+  }
+
+  for _, pkg := range []string{"goog.dom.scanned", "goog.unlisted", "outside"} {
+    if _, ok := g.Nodes[pkg]; ok {
+      t.Error("Package is not loaded from the deps file: ", pkg)
+    }
+  }
+
+  src, err := cc.readSources(expected[:1])
+  if err != nil || string(src) != "goog.provide('goog.array');" {
+    t.Error("Cannot read a file listed in a deps file: ", string(src), err)
+  }
+}
+
+func TestDepsFilesWithBase(t *testing.T) {
+  cc := newDepsCompiler()
+  cc.Roots[0].DepsBase = "goog/"
+  g := cc.DependencyGraph()
+  if node, ok := g.Nodes["goog.dom"]; !ok ||
+     node.Path != "closure/goog/dom/dom.js" {
+    t.Error("Invalid node: ", node)
+  }
+}
+
+func TestDepsRootsAreReadOnly(t *testing.T) {
+  dir := t.TempDir()
+  ioutil.WriteFile(filepath.Join(dir, "a.js"),
+                   []byte("goog.provide('a');\ngoog.require('b');\n"), 0644)
+  ioutil.WriteFile(filepath.Join(dir, "deps.js"),
+                   []byte("goog.addDependency('a.js', ['a'], ['b']);"), 0644)
+
+  cc := NewCompilerWithSources(fstest.MapFS{})
+  cc.Roots = []SourceRoot{{
+    Name: "third-party",
+    Sources: DirSources(dir),
+    Prefix: "third_party",
+    DepsFiles: []string{"deps.js"},
+  }}
+
+  fixes := []FileFix{{"third_party/a.js", "", "goog.provide('a');\n"}}
+  if err := cc.ApplyFixes(fixes); err == nil {
+    t.Error("File in a deps root is written.")
+  }
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "fmt"
+  "regexp"
+  "strconv"
+  "strings"
+
+  "github.com/soheilhy/glosure/depgraph"
+)
+
+type Severity string
+const (
+  SeverityError Severity = "error"
+  SeverityWarning = "warning"
+)
+
+// Diagnostic is an error or a warning reported for a source file.
+type Diagnostic struct {
+  Severity Severity `json:"severity"`
+  // Name of the file in the source tree, if known.
+  File string `json:"file,omitempty"`
+  // 1-based line and column numbers, or 0 if unknown.
+  Line int `json:"line,omitempty"`
+  Column int `json:"column,omitempty"`
+  Message string `json:"message"`
+  // Name of the lint check reporting the diagnostic (e.g.,
+  // CheckUnusedRequire), if any.
+  Check string `json:"check,omitempty"`
+}
+
+// Formats the diagnostic as "file:line:column: severity: message".
+func (d Diagnostic) String() string {
+  pos := d.File
+  if d.Line != 0 {
+    pos += ":" + strconv.Itoa(d.Line)
+    if d.Column != 0 {
+      pos += ":" + strconv.Itoa(d.Column)
+    }
+  }
+
+  if pos == "" {
+    return fmt.Sprintf("%s: %s", d.Severity, d.Message)
+  }
+  return fmt.Sprintf("%s: %s: %s", pos, d.Severity, d.Message)
+}
+
+// CompilationError is returned when a target cannot be compiled. Diagnostics
+// holds the errors and warnings reported for the target.
+type CompilationError struct {
+  Target string
+  Diagnostics []Diagnostic
+  Err error
+}
+
+func (e *CompilationError) Error() string {
+  msgs := []string{}
+  for _, d := range e.Diagnostics {
+    if d.Severity == SeverityError {
+      msgs = append(msgs, d.String())
+    }
+  }
+
+  if len(msgs) == 0 {
+    return fmt.Sprintf("Cannot compile %s: %v", e.Target, e.Err)
+  }
+  return fmt.Sprintf("Cannot compile %s:\n%s", e.Target,
+                     strings.Join(msgs, "\n"))
+}
+
+func (e *CompilationError) Unwrap() error {
+  return e.Err
+}
+
+// Returns whether any of the diagnostics is an error.
+func hasErrors(diags []Diagnostic) bool {
+  for _, d := range diags {
+    if d.Severity == SeverityError {
+      return true
+    }
+  }
+  return false
+}
+
+// Returns the cycles of the dependency graph as warnings. If pkgs is not nil,
+// only the cycles going through any of pkgs are returned.
+func cycleDiagnostics(g depgraph.DependencyGraph,
+                      pkgs map[string]bool) []Diagnostic {
+  diags := []Diagnostic{}
+  for _, cycle := range g.Cycles() {
+    touched := pkgs == nil
+    for _, step := range cycle.Steps {
+      touched = touched || pkgs[step.Pkg]
+    }
+
+    if !touched {
+      continue
+    }
+
+    diags = append(diags, Diagnostic{
+      Severity: SeverityWarning,
+      File: cycle.Steps[0].Path,
+      Line: cycle.Steps[0].Line,
+      Message: "Circular dependency: " + cycle.String(),
+    })
+  }
+  return diags
+}
+
+// Matches the errors and warnings printed by the closure compiler (e.g.,
+// "app.js:12: WARNING - [JSC_UNUSED] unused variable").
+var javaDiagnosticRegex = regexp.MustCompile(
+    `(?m)^(.+?):(\d+)(?::(\d+))?: (ERROR|WARNING) - (.*)$`)
+
+// Parses the diagnostics printed by the closure compiler on its standard
+// error. Local paths are mapped back to names in the source tree using names,
+// which maps local paths to source names.
+func parseJavaDiagnostics(output string,
+                          names map[string]string) []Diagnostic {
+  diags := []Diagnostic{}
+  for _, m := range javaDiagnosticRegex.FindAllStringSubmatch(output, -1) {
+    d := Diagnostic{
+      Severity: SeverityWarning,
+      File: m[1],
+      Message: strings.TrimSpace(m[5]),
+    }
+
+    if m[4] == "ERROR" {
+      d.Severity = SeverityError
+    }
+
+    if name, ok := names[d.File]; ok {
+      d.File = name
+    }
+
+    d.Line, _ = strconv.Atoi(m[2])
+    d.Column, _ = strconv.Atoi(m[3])
+    diags = append(diags, d)
+  }
+  return diags
+}
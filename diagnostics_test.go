@@ -0,0 +1,94 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "errors"
+  "strings"
+  "testing"
+  "testing/fstest"
+)
+
+func TestParseJavaDiagnostics(t *testing.T) {
+  output := "/tmp/x/lib/app.js:12: WARNING - [JSC_UNUSED] unused var\n" +
+            "var a = 1;\n" +
+            "    ^\n" +
+            "/tmp/x/lib/app.js:20:4: ERROR - Parse error. missing ;\n" +
+            "1 error(s), 1 warning(s)\n"
+
+  names := map[string]string{"/tmp/x/lib/app.js": "app.js"}
+  diags := parseJavaDiagnostics(output, names)
+  if len(diags) != 2 {
+    t.Fatal("Invalid diagnostics: ", diags)
+  }
+
+  if diags[0].Severity != SeverityWarning || diags[0].File != "app.js" ||
+     diags[0].Line != 12 || diags[0].Column != 0 {
+    t.Error("Invalid warning: ", diags[0])
+  }
+
+  if diags[1].String() != "app.js:20:4: error: Parse error. missing ;" {
+    t.Error("Invalid error: ", diags[1])
+  }
+}
+
+func TestCompilationError(t *testing.T) {
+  err := &CompilationError{
+    Target: "app.min.js",
+    Diagnostics: []Diagnostic{
+      {Severity: SeverityWarning, Message: "unused"},
+      {Severity: SeverityError, File: "app.js", Line: 3, Message: "broken"},
+    },
+    Err: errors.New("exit status 1"),
+  }
+
+  msg := err.Error()
+  if !strings.Contains(msg, "app.js:3: error: broken") ||
+     strings.Contains(msg, "unused") {
+    t.Error("Invalid error message: ", msg)
+  }
+
+  err.Diagnostics = nil
+  if err.Error() != "Cannot compile app.min.js: exit status 1" {
+    t.Error("Invalid error message without diagnostics: ", err.Error())
+  }
+}
+
+func TestCycleDiagnostics(t *testing.T) {
+  cc := NewCompilerWithSources(fstest.MapFS{
+    "a.js": {Data: []byte("goog.provide('a');\ngoog.require('b');")},
+    "b.js": {Data: []byte("goog.provide('b');\n\ngoog.require('a');")},
+    "c.js": {Data: []byte("goog.provide('c');")},
+  })
+
+  diags := cycleDiagnostics(cc.DependencyGraph(), nil)
+  if len(diags) != 1 || diags[0].File != "a.js" || diags[0].Line != 2 {
+    t.Fatal("Invalid cycle diagnostics: ", diags)
+  }
+
+  expected := "Circular dependency: a (a.js:2) -> b (b.js:3) -> a"
+  if diags[0].Message != expected {
+    t.Error("Invalid message: ", diags[0].Message)
+  }
+
+  diags = cycleDiagnostics(cc.DependencyGraph(), map[string]bool{"c": true})
+  if len(diags) != 0 {
+    t.Error("Cycle not touching the packages is reported: ", diags)
+  }
+
+  if len(cc.Status().Diagnostics) != 1 {
+    t.Error("Cycle is not in the status: ", cc.Status().Diagnostics)
+  }
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "bytes"
+  "compress/gzip"
+  "io"
+  "net/http"
+  "strconv"
+  "strings"
+)
+
+// ContentEncoder writes precompressed variants of compiled outputs, which are
+// served to clients accepting the encoding.
+type ContentEncoder interface {
+  // Name of the encoding as used in Accept-Encoding (e.g., "gzip").
+  Encoding() string
+  // Suffix of the encoded file (e.g., ".gz").
+  Suffix() string
+  // Encodes src into dst.
+  Encode(dst io.Writer, src []byte) error
+}
+
+// Encodes outputs using gzip with the best compression.
+type GzipEncoder struct{}
+
+func (e GzipEncoder) Encoding() string {
+  return "gzip"
+}
+
+func (e GzipEncoder) Suffix() string {
+  return ".gz"
+}
+
+func (e GzipEncoder) Encode(dst io.Writer, src []byte) error {
+  w, err := gzip.NewWriterLevel(dst, gzip.BestCompression)
+  if err != nil {
+    return err
+  }
+
+  _, err = w.Write(src)
+  if err != nil {
+    return err
+  }
+  return w.Close()
+}
+
+// Parses the Accept-Encoding header into a map from encodings to their
+// quality values.
+func parseAcceptEncoding(header string) map[string]float64 {
+  accepted := make(map[string]float64)
+  for _, part := range strings.Split(header, ",") {
+    fields := strings.Split(part, ";")
+    enc := strings.ToLower(strings.TrimSpace(fields[0]))
+    if enc == "" {
+      continue
+    }
+
+    q := 1.0
+    for _, param := range fields[1:] {
+      param = strings.TrimSpace(param)
+      if !strings.HasPrefix(param, "q=") {
+        continue
+      }
+
+      v, err := strconv.ParseFloat(param[2:], 64)
+      if err == nil {
+        q = v
+      }
+    }
+    accepted[enc] = q
+  }
+  return accepted
+}
+
+// Returns the first encoder in Compiler.Encoders accepted by the request, or
+// nil if the request accepts none.
+func (cc *Compiler) negotiateEncoder(req *http.Request) ContentEncoder {
+  header := req.Header.Get("Accept-Encoding")
+  if header == "" {
+    return nil
+  }
+
+  accepted := parseAcceptEncoding(header)
+  for _, enc := range cc.Encoders {
+    q, ok := accepted[enc.Encoding()]
+    if !ok {
+      q, ok = accepted["*"]
+    }
+
+    if ok && q > 0 {
+      return enc
+    }
+  }
+  return nil
+}
+
+// Writes the encoded variant of an output next to it.
+func (cc *Compiler) writeEncodedOutput(outName string,
+                                       enc ContentEncoder) error {
+  content, err := cc.Outputs.ReadFile(outName)
+  if err != nil {
+    return err
+  }
+
+  var buffer bytes.Buffer
+  err = enc.Encode(&buffer, content)
+  if err != nil {
+    return err
+  }
+  return cc.Outputs.WriteFile(outName + enc.Suffix(), buffer.Bytes())
+}
+
+// Returns the name of the encoded variant of an output. The variant is
+// rewritten if it is missing or out of date.
+func (cc *Compiler) getEncodedOutput(outName string,
+                                     enc ContentEncoder) (string, error) {
+  encName := outName + enc.Suffix()
+  outStat, err := cc.Outputs.Stat(outName)
+  if err != nil {
+    return "", err
+  }
+
+  encStat, err := cc.Outputs.Stat(encName)
+  if err == nil && !encStat.ModTime().Before(outStat.ModTime()) {
+    return encName, nil
+  }
+
+  err = cc.writeEncodedOutput(outName, enc)
+  if err != nil {
+    return "", err
+  }
+  return encName, nil
+}
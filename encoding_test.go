@@ -0,0 +1,97 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "bytes"
+  "compress/gzip"
+  "io/ioutil"
+  "net/http"
+  "net/http/httptest"
+  "testing"
+)
+
+func TestGzipEncoder(t *testing.T) {
+  var buffer bytes.Buffer
+  err := GzipEncoder{}.Encode(&buffer, []byte("var app={};"))
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  r, err := gzip.NewReader(&buffer)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  content, _ := ioutil.ReadAll(r)
+  if string(content) != "var app={};" {
+    t.Error("Invalid gzip content: ", string(content))
+  }
+}
+
+func TestNegotiateEncoder(t *testing.T) {
+  cc := NewCompiler(".")
+  headers := [...]struct {
+    AcceptEncoding string
+    Accepted bool
+  }{
+    {"", false},
+    {"gzip", true},
+    {"deflate, gzip;q=0.5", true},
+    {"gzip;q=0", false},
+    {"br", false},
+    {"*", true},
+    {"*, gzip;q=0", false},
+  }
+
+  for _, h := range headers {
+    req, _ := http.NewRequest("GET", "/app.min.js", nil)
+    req.Header.Set("Accept-Encoding", h.AcceptEncoding)
+    if (cc.negotiateEncoder(req) != nil) != h.Accepted {
+      t.Error("Invalid encoding negotiation for: ", h.AcceptEncoding)
+    }
+  }
+}
+
+func TestServeGzipOutput(t *testing.T) {
+  cc := NewCompiler(newCompiledRoot(t))
+
+  res := httptest.NewRecorder()
+  req, _ := http.NewRequest("GET", "/app.min.js", nil)
+  req.Header.Set("Accept-Encoding", "gzip")
+  ServeHttp(res, req, &cc)
+  if res.Header().Get("Content-Encoding") != "gzip" ||
+     res.Header().Get("Vary") != "Accept-Encoding" {
+    t.Fatal("Invalid headers for gzip output: ", res.Header())
+  }
+
+  r, err := gzip.NewReader(res.Body)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  content, _ := ioutil.ReadAll(r)
+  if string(content) != "var app={};\n" {
+    t.Error("Invalid gzip output: ", string(content))
+  }
+
+  res = httptest.NewRecorder()
+  req, _ = http.NewRequest("GET", "/app.min.js", nil)
+  ServeHttp(res, req, &cc)
+  if res.Header().Get("Content-Encoding") != "" ||
+     res.Body.String() != "var app={};\n" {
+    t.Error("Served gzip output to a client not accepting it.")
+  }
+}
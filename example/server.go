@@ -51,7 +51,7 @@ func main() {
 
   cc.UseClosureApi = *noJava
 
-  http.Handle("/", glosure.GlosureServer(cc))
+  http.Handle("/", glosure.GlosureServer(&cc))
   fmt.Println("Checkout http://localhost:8080/sample.min.js?force=1")
   http.ListenAndServe(":8080", nil);
 }
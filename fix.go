@@ -0,0 +1,256 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "bytes"
+  "errors"
+  "fmt"
+  "io/fs"
+  "path/filepath"
+  "regexp"
+  "sort"
+  "strings"
+
+  "github.com/soheilhy/glosure/depgraph"
+)
+
+// FileFix is the fixed content of a source file.
+type FileFix struct {
+  File string
+  Before string
+  After string
+}
+
+// Number of unchanged lines shown around the changes in Diff.
+const diffContext = 3
+
+// Splits the content into lines keeping their line breaks.
+func splitLines(content string) []string {
+  lines := strings.SplitAfter(content, "\n")
+  if lines[len(lines) - 1] == "" {
+    lines = lines[:len(lines) - 1]
+  }
+  return lines
+}
+
+// Returns the fix as a unified diff.
+func (f FileFix) Diff() string {
+  before := splitLines(f.Before)
+  after := splitLines(f.After)
+
+  // Fixes change a single block of lines, so the hunk is everything between
+  // the common prefix and suffix.
+  prefix := 0
+  for prefix < len(before) && prefix < len(after) &&
+      before[prefix] == after[prefix] {
+    prefix++
+  }
+
+  suffix := 0
+  for suffix < len(before) - prefix && suffix < len(after) - prefix &&
+      before[len(before) - 1 - suffix] == after[len(after) - 1 - suffix] {
+    suffix++
+  }
+
+  start := prefix - diffContext
+  if start < 0 {
+    start = 0
+  }
+
+  trailing := suffix
+  if trailing > diffContext {
+    trailing = diffContext
+  }
+
+  var buf bytes.Buffer
+  fmt.Fprintf(&buf, "--- a/%s\n+++ b/%s\n", f.File, f.File)
+  beforeEnd := len(before) - suffix + trailing
+  afterEnd := len(after) - suffix + trailing
+  fmt.Fprintf(&buf, "@@ -%d,%d +%d,%d @@\n", start + 1, beforeEnd - start,
+              start + 1, afterEnd - start)
+
+  writeLines := func(mark string, lines []string) {
+    for _, line := range lines {
+      buf.WriteString(mark + line)
+      if !strings.HasSuffix(line, "\n") {
+        buf.WriteString("\n\\ No newline at end of file\n")
+      }
+    }
+  }
+
+  writeLines(" ", before[start:prefix])
+  writeLines("-", before[prefix:len(before) - suffix])
+  writeLines("+", after[prefix:len(after) - suffix])
+  writeLines(" ", before[len(before) - suffix:beforeEnd])
+  return buf.String()
+}
+
+// Matches a line that contains only a goog.require statement.
+var requireLineRegex = regexp.MustCompile(
+    `^\s*goog\.require\(\s*(['"])([^'"]+)['"]\s*\)\s*;?\s*$`)
+
+var provideLineRegex = regexp.MustCompile(`^\s*goog\.provide\(`)
+
+// Rewrites the requires of a file: adds the requires of referenced namespaces
+// that the graph knows about, removes unused requires, and sorts them. The
+// sorted block replaces the first require line, and the rest of the file is
+// kept intact. namespaces are the namespaces of the graph. goog.modules and
+// ES modules bind their requires to names, so they are left as is.
+func fixRequires(src string, scan scanResult, g depgraph.DependencyGraph,
+                 namespaces map[string]bool) string {
+  if scan.Module == googModule || scan.Module == es6Module {
+    return src
+  }
+
+  provided := make(map[string]bool)
+  for _, pkg := range scan.Provides {
+    provided[pkg] = true
+  }
+
+  refs := referencedNamespaces(src, namespaces, scan.Requires)
+  lines := strings.SplitAfter(src, "\n")
+  quote := "'"
+  first := -1
+  lastProvide := -1
+  requireLines := make(map[int]bool)
+  onOwnLine := make(map[string]bool)
+  for i, line := range lines {
+    if provideLineRegex.MatchString(line) {
+      lastProvide = i
+    }
+
+    m := requireLineRegex.FindStringSubmatch(strings.TrimRight(line, "\r\n"))
+    if m == nil {
+      continue
+    }
+
+    if first < 0 {
+      first = i
+      quote = m[1]
+    }
+    requireLines[i] = true
+    onOwnLine[m[2]] = true
+  }
+
+  // Requires that are not on a line of their own (e.g., in goog.module
+  // assignments) are left as is.
+  elsewhere := make(map[string]bool)
+  for _, req := range scan.Requires {
+    if !onOwnLine[req.Namespace] {
+      elsewhere[req.Namespace] = true
+    }
+  }
+
+  requires := []string{}
+  for ns := range refs {
+    _, known := g.Nodes[ns]
+    if !provided[ns] && !elsewhere[ns] && (onOwnLine[ns] || known) {
+      requires = append(requires, ns)
+    }
+  }
+  sort.Strings(requires)
+
+  newline := "\n"
+  if strings.HasSuffix(lines[0], "\r\n") {
+    newline = "\r\n"
+  }
+
+  block := ""
+  for _, ns := range requires {
+    block += "goog.require(" + quote + ns + quote + ");" + newline
+  }
+
+  if first < 0 {
+    if block == "" || lastProvide < 0 {
+      return src
+    }
+
+    // Without any require, the block goes after the provides.
+    first = lastProvide + 1
+    block = newline + block
+    if !strings.HasSuffix(lines[lastProvide], "\n") {
+      block = newline + block
+    }
+  }
+
+  var out strings.Builder
+  for i, line := range lines {
+    if i == first {
+      out.WriteString(block)
+    }
+
+    if !requireLines[i] {
+      out.WriteString(line)
+    }
+  }
+  return out.String()
+}
+
+// Returns the fixes of the requires of the given source files, or of all
+// JavaScript files in Compiler.Sources if no file is given. Files that need no
+// change are omitted. See Compiler.Lint for the checks. Files without provides
+// are fixed if they have requires, and goog.modules and ES modules are never
+// changed.
+func (cc *Compiler) FixRequires(names ...string) ([]FileFix, error) {
+  if len(names) == 0 {
+    names = cc.lintableSources()
+  }
+
+  g := *cc.sharedGraph()
+  namespaces := graphNamespaces(g)
+  fixes := []FileFix{}
+  for _, name := range names {
+    name = cleanName(name)
+    content, err := fs.ReadFile(cc.sourceTree(), name)
+    if err != nil {
+      return nil, err
+    }
+
+    src := string(content)
+    fixed := fixRequires(src, scanClosureSource(src), g, namespaces)
+    if fixed != src {
+      fixes = append(fixes, FileFix{name, src, fixed})
+    }
+  }
+  return fixes, nil
+}
+
+// Writes the fixes into the source files. Only files in a DirSources tree can
+// be written, and roots listed by deps files are read-only.
+func (cc *Compiler) ApplyFixes(fixes []FileFix) error {
+  for _, fix := range fixes {
+    root, rel, ok := cc.resolveSource(fix.File)
+    d, isDir := root.Sources.(interface{ Dir() string })
+    if !ok || !isDir {
+      return errors.New("Cannot write " + fix.File + ": not in a directory.")
+    }
+
+    if len(root.DepsFiles) != 0 {
+      return errors.New("Cannot write " + fix.File + ": root " + root.Name +
+                        " is read-only.")
+    }
+
+    path := filepath.Join(d.Dir(), filepath.FromSlash(rel))
+    err := writeFileAtomically(path, []byte(fix.After))
+    if err != nil {
+      return err
+    }
+  }
+
+  // The graph is reloaded on the next use.
+  cc.graph.reset()
+  return nil
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "testing"
+  "testing/fstest"
+)
+
+func TestFixRequires(t *testing.T) {
+  cc := newLintCompiler()
+  fixes, err := cc.FixRequires("app.js")
+  if err != nil || len(fixes) != 1 {
+    t.Fatal("Invalid fixes: ", fixes, err)
+  }
+
+  expected := `goog.provide('app');
+
+goog.require('app.util');
+goog.require('goog.array');
+goog.require('goog.dom');
+goog.require('goog.dom.classlist');
+
+// goog.events is mentioned in a comment only.
+app.main = function() {
+  goog.dom.classlist.add(goog.dom.getElement('x'), 'y');
+  goog.array.forEach([], app.util.noop);
+  var s = 'goog.events';
+};
+`
+  if fixes[0].After != expected {
+    t.Error("Invalid fixed file: ", fixes[0].After)
+  }
+
+  expectedDiff := `--- a/app.js
++++ b/app.js
+@@ -1,9 +1,9 @@
+ goog.provide('app');
+ 
+-goog.require('goog.dom');
+-goog.require('goog.array');
+-goog.require('app.missing');
+-goog.require('goog.string');
++goog.require('app.util');
++goog.require('goog.array');
++goog.require('goog.dom');
++goog.require('goog.dom.classlist');
+ 
+ // goog.events is mentioned in a comment only.
+ app.main = function() {
+`
+  if fixes[0].Diff() != expectedDiff {
+    t.Error("Invalid diff: ", fixes[0].Diff())
+  }
+
+  fixes, err = cc.FixRequires("lib/dom.js")
+  if err != nil || len(fixes) != 0 {
+    t.Error("File without problems is fixed: ", fixes, err)
+  }
+}
+
+func TestFixRequiresWithoutRequires(t *testing.T) {
+  cc := NewCompilerWithSources(fstest.MapFS{
+    "a.js": {Data: []byte("goog.provide(\"a\");\nb.run();")},
+    "b.js": {Data: []byte("goog.provide('b');")},
+  })
+
+  fixes, err := cc.FixRequires("a.js")
+  expected := "goog.provide(\"a\");\n\ngoog.require('b');\nb.run();"
+  if err != nil || len(fixes) != 1 || fixes[0].After != expected {
+    t.Errorf("Invalid fixes: %q %v", fixes, err)
+  }
+}
+
+func TestFixRequiresKeepsWeakRequires(t *testing.T) {
+  src := "goog.provide('a');\n\ngoog.requireType('a.b');\n" +
+         "goog.require('a.c');\n\na.b.run(a.c.x);\n"
+  cc := NewCompilerWithSources(fstest.MapFS{
+    "a.js": {Data: []byte(src)},
+    "b.js": {Data: []byte("goog.provide('a.b');")},
+    "c.js": {Data: []byte("goog.provide('a.c');")},
+  })
+
+  fixes, err := cc.FixRequires("a.js")
+  if err != nil || len(fixes) != 0 {
+    t.Errorf("Type-only require is changed: %q %v", fixes, err)
+  }
+}
+
+func TestFixRequiresWithoutProvides(t *testing.T) {
+  module := "goog.module('m');\n\nconst b = goog.require('a.b');\n" +
+            "exports.x = a.c.x;\n"
+  cc := NewCompilerWithSources(fstest.MapFS{
+    "main.js": {Data: []byte("goog.require('a.b');\n\na.b.run(a.c.x);\n")},
+    "m.js": {Data: []byte(module)},
+    "b.js": {Data: []byte("goog.provide('a.b');")},
+    "c.js": {Data: []byte("goog.provide('a.c');")},
+  })
+
+  // Scripts with requires are fixed, but goog.modules are not.
+  fixes, err := cc.FixRequires("main.js", "m.js")
+  expected := "goog.require('a.b');\ngoog.require('a.c');\n\na.b.run(a.c.x);\n"
+  if err != nil || len(fixes) != 1 || fixes[0].File != "main.js" ||
+     fixes[0].After != expected {
+    t.Errorf("Invalid fixes: %q %v", fixes, err)
+  }
+}
+
+func TestApplyFixes(t *testing.T) {
+  dir := t.TempDir()
+  ioutil.WriteFile(filepath.Join(dir, "a.js"),
+                   []byte("goog.provide('a');\ngoog.require('b');\n"), 0644)
+  ioutil.WriteFile(filepath.Join(dir, "b.js"),
+                   []byte("goog.provide('b');\n"), 0644)
+  os.Chmod(filepath.Join(dir, "a.js"), 0600)
+
+  cc := NewCompiler(dir)
+  fixes, err := cc.FixRequires()
+  if err != nil || len(fixes) != 1 {
+    t.Fatal("Invalid fixes: ", fixes, err)
+  }
+
+  if err = cc.ApplyFixes(fixes); err != nil {
+    t.Fatal(err)
+  }
+
+  content, _ := ioutil.ReadFile(filepath.Join(dir, "a.js"))
+  if string(content) != "goog.provide('a');\n" {
+    t.Errorf("Invalid fixed file: %q", content)
+  }
+
+  stat, err := os.Stat(filepath.Join(dir, "a.js"))
+  if err != nil || stat.Mode().Perm() != 0600 {
+    t.Error("Fixed file does not keep its permissions: ", stat.Mode())
+  }
+
+  mem := NewCompilerWithSources(fstest.MapFS{})
+  if mem.ApplyFixes(fixes) == nil {
+    t.Error("Fixes are written into a read-only tree.")
+  }
+}
@@ -25,36 +25,45 @@ import (
   "encoding/json"
   "fmt"
   "io"
+  "io/fs"
   "io/ioutil"
   "net/http"
   "net/url"
   "os"
   "os/exec"
-  "path"
   "path/filepath"
   "regexp"
+  "runtime"
   "strings"
   "sync"
+  "time"
   "archive/zip"
 
   "github.com/golang/glog"
   "github.com/soheilhy/glosure/depgraph"
 )
 
-// Creates an http.Handler using the closure compiler.
-func GlosureServer(cc Compiler) http.Handler {
+// Creates an http.Handler using the closure compiler. Handlers of the same
+// compiler, such as StylesheetServer, share its outputs and lock.
+func GlosureServer(cc *Compiler) http.Handler {
   return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
-    ServeHttp(res, req, &cc)
+    ServeHttp(res, req, cc)
   })
 }
 
 // Creates a glosure http handler for the given root directory.
 func GlosureServerWithRoot(root string) http.Handler {
-  return GlosureServer(NewCompiler(root))
+  cc := NewCompiler(root)
+  return GlosureServer(&cc)
 }
 
 const DefaultCompiledSuffix = ".min.js"
 const DefaultSourceSuffix = ".js"
+const DefaultCompiledCssSuffix = ".min.css"
+const DefaultCssRenamingMapSuffix = ".cssmap.js"
+const DefaultSoySuffix = ".soy"
+const DefaultManifestName = "manifest.json"
+const DefaultFingerprintLength = 8
 
 type CompilationLevel string
 const (
@@ -76,6 +85,30 @@ const (
   PrintInputDelimiter = "print_input_delimiter"
 )
 
+// CachePolicy specifies the Cache-Control headers of compiled outputs. Empty
+// values result in no Cache-Control header.
+type CachePolicy struct {
+  // Cache-Control of outputs served under their logical names (e.g.,
+  // "/app.min.js").
+  Default string
+  // Cache-Control of outputs served under their fingerprinted names (e.g.,
+  // "/app.3f9a1c2b.min.js").
+  Fingerprinted string
+}
+
+// Revalidates outputs served under logical names on every request, and caches
+// fingerprinted outputs for a year.
+var DefaultCachePolicy = CachePolicy{
+  Default: "no-cache",
+  Fingerprinted: "public, max-age=31536000, immutable",
+}
+
+// Revalidates all outputs on every request.
+var DevCachePolicy = CachePolicy{
+  Default: "no-cache",
+  Fingerprinted: "no-cache",
+}
+
 type WarningClass string
 const (
   AccessControls = "accessControls"
@@ -119,6 +152,41 @@ const (
 type Compiler struct {
   // Path containing all JavaScript sources.
   Root string
+  // Source tree of the compiler. Uses the directory in Compiler.Root by
+  // default, but can be any fs.FS (e.g., an embed.FS, a zip.Reader, or trees
+  // created by HttpSources and OverlaySources). Note that Externs and
+  // BaseFiles are always paths on the local file system.
+  Sources fs.FS
+  // Additional source roots in the order of priority (e.g., Closure Library
+  // and third-party Closure code). Compiler.Sources has the highest priority
+  // and is mounted at the root of the URL space.
+  Roots []SourceRoot
+  // What to do when more than one file provides the same namespace. Valid
+  // policies are: WarnOnConflict (default), FailOnConflict, and
+  // ResolveByRootPriority.
+  ConflictPolicy ConflictPolicy
+  // Order of the dependencies of a target that do not depend on one another.
+  // Valid orders are: depgraph.RequireOrder (default), which follows the
+  // requires of every file, and depgraph.NamespaceOrder. Either way, the same
+  // sources give the same order.
+  DependencyOrder depgraph.Order
+  // Gitignore patterns of the source files that are not scanned, matched
+  // against their names in the source tree (e.g., "closure/**/*_test.js").
+  // Uses DefaultSourceExcludes by default. .glosureignore files in the roots
+  // add patterns for their directories.
+  SourceExcludes []string
+  // Gitignore patterns of the source files that are scanned. All files that
+  // are not excluded are scanned if empty.
+  SourceIncludes []string
+  // Number of source files scanned at once. Uses the number of CPUs if 0.
+  ScanWorkers int
+  // Path of a file keeping the scans of the source files between processes
+  // (e.g., "/var/cache/glosure/index.json"). At startup, only files changed
+  // since their last scan are scanned again. Not used if empty.
+  IndexPath string
+  // Whether to serve the sources under the URL prefix of their roots as is.
+  // Useful for debugging uncompiled code.
+  ServeSources bool
   // Compiled JavaScript suffix. Uses ".min.js" by default.
   CompiledSuffix string
   // JavaScript source suffix. Uses ".js" by default.
@@ -128,7 +196,7 @@ type Compiler struct {
   ErrorHandler http.HandlerFunc
 
   // Path of Closure's "compiler.jar". By default Glosure downloads the latest
-  // compiler onto Compiler.Root.
+  // compiler onto Compiler.Root, or the temporary directory if Root is empty.
   CompilerJarPath string
 
   // Compile source javascripts if not compiled or out of date.
@@ -167,8 +235,54 @@ type Compiler struct {
   // Warnings that are suppressed.
   CompSuppressed []WarningClass
 
-  fileServer http.Handler
-  depg depgraph.DependencyGraph
+  // Compiled stylesheet suffix. Uses ".min.css" by default.
+  CompiledCssSuffix string
+  // Stylesheet source suffixes in the order of preference. Uses ".gss" and
+  // ".css" by default.
+  CssSourceSuffixes []string
+  // Suffix of the CSS renaming map generated for each stylesheet target. Uses
+  // ".cssmap.js" by default.
+  CssRenamingMapSuffix string
+  // Path of Closure Stylesheets' jar file. Stylesheets are compiled only if
+  // this is set.
+  StylesheetsJarPath string
+  // Closure Stylesheets renaming mode. Valid modes are: NoRenaming (default),
+  // DebugRenaming, and ClosureRenaming.
+  CssRenaming CssRenaming
+
+  // Path of Closure Templates' "SoyToJsSrcCompiler.jar". Soy templates under
+  // Compiler.Root are compiled only if this is set.
+  SoyCompilerJarPath string
+  // Soy template suffix. Uses ".soy" by default. Templates are compiled into
+  // files with the same name plus Compiler.SourceSuffix (e.g., "foo.soy.js").
+  SoySuffix string
+
+  // Store of compiled outputs. Uses a DirStore on Compiler.Root by default, so
+  // outputs are written next to their sources.
+  Outputs OutputStore
+
+  // Name of the JSON manifest in Compiler.Outputs that maps compiled outputs
+  // to their fingerprinted names. Uses "manifest.json" by default.
+  ManifestName string
+  // Number of hex digits of the content hash used in fingerprinted names.
+  // Uses 8 by default, or if it is not positive, and at most 64.
+  FingerprintLength int
+
+  // Cache-Control policy of compiled outputs. Uses DefaultCachePolicy by
+  // default, and DevCachePolicy in debug mode.
+  CachePolicy CachePolicy
+
+  // Encoders used to write precompressed variants of compiled outputs. Uses
+  // GzipEncoder by default.
+  Encoders []ContentEncoder
+
+  manifest *manifest
+  scans *scanCache
+  stats *compileStats
+  graph *graphSnapshot
+  // Registry of the compiler, if any.
+  registry *Registry
+  // Serializes compilations.
   mutex sync.Mutex
 }
 
@@ -176,19 +290,44 @@ func NewCompiler(root string) Compiler {
   _, javaLookupErr := exec.LookPath("java")
   return Compiler{
     Root: root,
+    Sources: DirSources(root),
     ErrorHandler: http.NotFound,
     CompiledSuffix: DefaultCompiledSuffix,
     CompilationLevel: SimpleOptimizations,
     WarningLevel: Default,
     SourceSuffix: DefaultSourceSuffix,
+    CompiledCssSuffix: DefaultCompiledCssSuffix,
+    CssSourceSuffixes: []string{".gss", ".css"},
+    CssRenamingMapSuffix: DefaultCssRenamingMapSuffix,
+    CssRenaming: NoRenaming,
+    SoySuffix: DefaultSoySuffix,
+    Outputs: NewDirStore(root),
+    ManifestName: DefaultManifestName,
+    FingerprintLength: DefaultFingerprintLength,
+    manifest: newManifest(),
+    scans: newScanCache(),
+    stats: newCompileStats(),
     CompileOnDemand: true,
     UseClosureApi: javaLookupErr != nil,
-    fileServer: http.FileServer(http.Dir(root)),
-    depg: depgraph.New(),
+    CachePolicy: DefaultCachePolicy,
+    ConflictPolicy: WarnOnConflict,
+    DependencyOrder: depgraph.RequireOrder,
+    SourceExcludes: DefaultSourceExcludes,
+    Encoders: []ContentEncoder{GzipEncoder{}},
+    graph: newGraphSnapshot(),
     mutex: sync.Mutex{},
   }
 }
 
+// Creates a compiler for the given source tree. Compiled outputs are kept in
+// memory; set Compiler.Outputs to store them elsewhere.
+func NewCompilerWithSources(sources fs.FS) *Compiler {
+  cc := NewCompiler("")
+  cc.Sources = sources
+  cc.Outputs = NewMemoryStore()
+  return &cc
+}
+
 // Enables strict compilation. Almost all warnings are treated as errors.
 func (cc *Compiler) Strict() {
   cc.WarningLevel = Verbose
@@ -224,13 +363,19 @@ func (cc *Compiler) Debug() {
   cc.CompErrors = []WarningClass{}
   cc.CompSuppressed = []WarningClass{}
   cc.Formatting = PrettyPrint
+  cc.CachePolicy = DevCachePolicy
 }
 
 // Glosure's main handler function.
 func ServeHttp(res http.ResponseWriter, req *http.Request, cc *Compiler) {
-  path := req.URL.Path
+  path, hash := cc.parseFingerprintedPath(req.URL.Path)
 
   if !cc.isCompiledJavascript(path) {
+    if cc.ServeSources {
+      cc.serveSource(res, req)
+      return
+    }
+
     cc.ErrorHandler(res, req)
     return
   }
@@ -242,18 +387,109 @@ func ServeHttp(res http.ResponseWriter, req *http.Request, cc *Compiler) {
 
   forceCompile := req.URL.Query().Get("force") == "1"
   if !cc.CompileOnDemand || (!forceCompile && cc.jsIsAlreadyCompiled(path)) {
-    cc.fileServer.ServeHTTP(res, req)
+    cc.stats.recordHit()
+    if cc.CompileOnDemand {
+      cc.revalidateSource(cc.getSourceJavascriptName(path),
+                          cc.getCompiledJavascriptName(path))
+    }
+    cc.serveOutput(res, req, path, hash)
     return
   }
 
+  cc.stats.recordMiss()
   err := cc.Compile(path)
   if err != nil {
+    glog.Error(err)
     cc.ErrorHandler(res, req)
     return
   }
 
   glog.Info("JavaScript source is successfully compiled: ", path)
-  cc.fileServer.ServeHTTP(res, req)
+  cc.serveOutput(res, req, path, hash)
+}
+
+// Serves a compiled output with a strong ETag based on its content.
+// Fingerprinted outputs are served only if hash matches the current content of
+// the output. If the client accepts any of Compiler.Encoders, the encoded
+// variant is served.
+func (cc *Compiler) serveOutput(res http.ResponseWriter, req *http.Request,
+                                relPath string, hash string) {
+  etag, err := cc.contentHash(relPath)
+  if err != nil || (hash != "" && etag[:cc.fingerprintLength()] != hash) {
+    cc.ErrorHandler(res, req)
+    return
+  }
+
+  cacheControl := cc.CachePolicy.Default
+  if hash != "" {
+    cacheControl = cc.CachePolicy.Fingerprinted
+  }
+
+  if cacheControl != "" {
+    res.Header().Set("Cache-Control", cacheControl)
+  }
+
+  outName := cleanName(relPath)
+  servedName := outName
+  if len(cc.Encoders) != 0 {
+    res.Header().Add("Vary", "Accept-Encoding")
+  }
+
+  if enc := cc.negotiateEncoder(req); enc != nil {
+    encName, err := cc.getEncodedOutput(outName, enc)
+    if err == nil {
+      servedName = encName
+      etag += "-" + enc.Encoding()
+      res.Header().Set("Content-Encoding", enc.Encoding())
+    } else {
+      glog.Warning("Cannot encode ", outName, " with ", enc.Encoding(), ": ",
+                   err)
+    }
+  }
+
+  stat, err := cc.Outputs.Stat(servedName)
+  if err != nil {
+    cc.ErrorHandler(res, req)
+    return
+  }
+
+  content, err := cc.Outputs.ReadFile(servedName)
+  if err != nil {
+    cc.ErrorHandler(res, req)
+    return
+  }
+
+  // http.ServeContent responds to conditional requests using the ETag, and
+  // detects the content type from the name of the original output.
+  res.Header().Set("ETag", `"` + etag + `"`)
+  http.ServeContent(res, req, outName, stat.ModTime(),
+                    bytes.NewReader(content))
+}
+
+// Writes the file in outPath into Compiler.Outputs.
+func (cc *Compiler) storeOutput(outName string, outPath string) error {
+  content, err := ioutil.ReadFile(outPath)
+  if err != nil {
+    return err
+  }
+  return cc.Outputs.WriteFile(outName, content)
+}
+
+// Post-processes a compiled output: records its fingerprint and writes its
+// encoded variants.
+func (cc *Compiler) processOutput(outName string) error {
+  _, err := cc.fingerprint(outName)
+  if err != nil {
+    return err
+  }
+
+  for _, enc := range cc.Encoders {
+    err = cc.writeEncodedOutput(outName, enc)
+    if err != nil {
+      return err
+    }
+  }
+  return nil
 }
 
 func (cc *Compiler) isCompiledJavascript(path string) bool {
@@ -265,77 +501,98 @@ func (cc *Compiler) isSourceJavascript(path string) bool {
          !strings.HasSuffix(path, cc.CompiledSuffix)
 }
 
-func (cc *Compiler) getSourceJavascriptPath(relPath string) string {
-  return path.Join(cc.Root, relPath[:len(relPath) - len(cc.CompiledSuffix)] +
-                            cc.SourceSuffix)
+// Returns the name of the JavaScript source in Compiler.Sources.
+func (cc *Compiler) getSourceJavascriptName(relPath string) string {
+  return cleanName(relPath[:len(relPath) - len(cc.CompiledSuffix)] +
+                   cc.SourceSuffix)
 }
 
-func (cc *Compiler) getCompiledJavascriptPath(relPath string) string {
+// Returns the name of the compiled JavaScript in Compiler.Outputs.
+func (cc *Compiler) getCompiledJavascriptName(relPath string) string {
   if cc.isCompiledJavascript(relPath) {
-    return path.Join(cc.Root, relPath)
+    return cleanName(relPath)
   }
 
-  return path.Join(cc.Root, relPath[:len(relPath) - len(cc.SourceSuffix)] +
-                            cc.CompiledSuffix)
+  return cleanName(relPath[:len(relPath) - len(cc.SourceSuffix)] +
+                         cc.CompiledSuffix)
 }
 
 func (cc *Compiler) sourceFileExists(path string) bool {
-  srcPath := cc.getSourceJavascriptPath(path)
-  _, err := os.Stat(srcPath)
+  _, err := fs.Stat(cc.mountedSources(), cc.getSourceJavascriptName(path))
   return err == nil
 }
 
 func (cc *Compiler) jsIsAlreadyCompiled(path string) bool {
-  srcPath := cc.getSourceJavascriptPath(path)
-  srcStat, err := os.Stat(srcPath)
-  if err != nil {
+  outName := cc.getCompiledJavascriptName(path)
+  if !cc.isUpToDate(cc.getSourceJavascriptName(path), outName) {
     return false
   }
 
-  outPath := cc.getCompiledJavascriptPath(path)
-  outStat, err := os.Stat(outPath)
-  if err != nil {
+  outStat, err := cc.Outputs.Stat(outName)
+  if err != nil || !cc.templatesAreUpToDate(path, outStat.ModTime()) {
     return false
   }
 
-  if outStat.ModTime().Before(srcStat.ModTime()) {
-    return false
-  }
+  // A renaming map newer than the output means the stylesheet of the same
+  // target is recompiled and class names might have changed.
+  mapStat, err := cc.Outputs.Stat(cc.getCssRenamingMapName(path))
+  return err != nil || !outStat.ModTime().Before(mapStat.ModTime())
+}
+
+// URL of the closure compiler release downloaded when no jar is given.
+var compilerDownloadUrl =
+    "http://dl.google.com/closure-compiler/compiler-latest.zip"
 
-  return true
+// Returns the directory of the downloaded closure compiler, in the user cache
+// directory if there is one, so it does not need a writable source root.
+func compilerCacheDir() string {
+  dir, err := os.UserCacheDir()
+  if err != nil {
+    dir = os.TempDir()
+  }
+  return filepath.Join(dir, "glosure")
 }
 
 func (cc *Compiler) downloadCompilerJar() (string, error) {
-  const ccJarPath = "__compiler__.jar"
-  const ccDlUrl = "http://dl.google.com/closure-compiler/compiler-latest.zip"
+  const ccJarName = "__compiler__.jar"
 
-  jarFilePath := filepath.Join(cc.Root, ccJarPath)
-  _, err := os.Stat(jarFilePath)
-  if err == nil {
-    return jarFilePath, nil
+  // Jars downloaded into the root by earlier versions are still used.
+  if cc.Root != "" {
+    rootJarPath := filepath.Join(cc.Root, ccJarName)
+    if _, err := os.Stat(rootJarPath); err == nil {
+      return rootJarPath, nil
+    }
   }
 
-  glog.Info("Downloading closure compiler from: ", ccDlUrl)
+  jarFilePath := filepath.Join(compilerCacheDir(), ccJarName)
+  if _, err := os.Stat(jarFilePath); err == nil {
+    return jarFilePath, nil
+  }
 
-  zipFilePath := filepath.Join(cc.Root, "__compiler__.zip")
-  zipFile, err := os.Create(zipFilePath)
-  defer zipFile.Close()
+  glog.Info("Downloading closure compiler from: ", compilerDownloadUrl)
 
-  res, err := http.Get(ccDlUrl)
+  res, err := http.Get(compilerDownloadUrl)
+  if err != nil {
+    return "", err
+  }
   defer res.Body.Close()
 
+  if res.StatusCode != http.StatusOK {
+    return "", errors.New(fmt.Sprintf("Cannot download %s: %s",
+                                      compilerDownloadUrl, res.Status))
+  }
+
   // TODO(soheil): Maybe verify checksum?
-  _, err = io.Copy(zipFile, res.Body)
+  content, err := ioutil.ReadAll(res.Body)
   if err != nil {
     return "", err
   }
 
-  r, err := zip.OpenReader(zipFilePath)
+  r, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
   if err != nil {
     return "", err
   }
 
-  defer r.Close()
   for _, f := range r.File {
     if f.Name != "compiler.jar" {
       continue
@@ -345,19 +602,34 @@ func (cc *Compiler) downloadCompilerJar() (string, error) {
     if err != nil {
       return "", err
     }
+    defer cmpJar.Close()
 
-    jarFile, err := os.Create(jarFilePath)
-    defer jarFile.Close()
+    jar, err := ioutil.ReadAll(cmpJar)
+    if err != nil {
+      return "", err
+    }
 
     glog.V(1).Info("Decompressing compiler.jar to ", jarFilePath)
-    io.Copy(jarFile, cmpJar)
-    break
+    return jarFilePath, writeFileAtomically(jarFilePath, jar)
   }
 
-  return jarFilePath, nil
+  return "", errors.New(fmt.Sprintf("No compiler.jar in %s",
+                                    compilerDownloadUrl))
 }
 
-func (cc *Compiler) Compile(relOutPath string) error {
+// Compiles the JavaScript target. If the target cannot be compiled, the error
+// is a *CompilationError.
+func (cc *Compiler) Compile(relOutPath string) (err error) {
+  start := time.Now()
+  var diags, graphDiags []Diagnostic
+  defer func() {
+    if err == nil {
+      cc.updateManifest(cc.getCompiledJavascriptName(relOutPath))
+    }
+    err = cc.recordCompile(relOutPath, cc.backend(), start,
+                           append(graphDiags, diags...), err)
+  }()
+
   if !cc.UseClosureApi {
     _, err := exec.LookPath("java")
     if err != nil {
@@ -367,15 +639,21 @@ func (cc *Compiler) Compile(relOutPath string) error {
     if cc.CompilerJarPath == "" {
       cc.CompilerJarPath, err = cc.downloadCompilerJar()
       if err != nil {
-        glog.Fatal("Cannot download the closure compiler.")
+        glog.Fatal("Cannot download the closure compiler: ", err)
       }
     }
   }
 
-  srcPath := cc.getSourceJavascriptPath(relOutPath)
-  outPath := cc.getCompiledJavascriptPath(relOutPath)
+  srcName := cc.getSourceJavascriptName(relOutPath)
+  outName := cc.getCompiledJavascriptName(relOutPath)
 
-  srcPkgs, err := getClosurePackage(srcPath)
+  tmpDir, err := ioutil.TempDir("", "glosure")
+  if err != nil {
+    return err
+  }
+  defer os.RemoveAll(tmpDir)
+
+  srcPkgs, err := getClosurePackage(cc.mountedSources(), srcName)
   useClosureDeps := err == nil
 
   cc.mutex.Lock()
@@ -383,37 +661,122 @@ func (cc *Compiler) Compile(relOutPath string) error {
 
   jsFiles := make([]string, 0)
   if useClosureDeps {
-    if len(cc.depg.Nodes) == 0 {
-      cc.reloadDependencyGraph()
+    compiledTemplates, err := cc.compileTemplates()
+    if err != nil {
+      return err
     }
 
-    nodes := []*depgraph.Node{}
-    for _, srcPkg := range srcPkgs {
-      node, ok := cc.depg.Nodes[srcPkgs[0]]
-      if !ok {
-        return errors.New(fmt.Sprintf("Package %s not found in %s.", srcPkg,
-                                      cc.Root))
-      }
-      nodes = append(nodes, node)
+    // The compilation keeps using this snapshot even if the sources are
+    // rescanned meanwhile.
+    g := cc.graph.load()
+    if compiledTemplates || g == nil {
+      g = cc.reloadDependencyGraph()
     }
 
-    deps := cc.depg.GetDependencies(nodes)
+    var deps []*depgraph.Node
+    deps, err = cc.getDependencies(g, srcPkgs)
+    if err != nil {
+      return err
+    }
+
+    pkgs := make(map[string]bool)
     for _, dep := range(deps) {
       jsFiles = append(jsFiles, dep.Path)
+      pkgs[dep.Pkg] = true
+    }
+
+    // Cycles are broken arbitrarily in the graph, so they are reported with
+    // the compilation of every target they touch.
+    graphDiags = append(cycleDiagnostics(*g, pkgs),
+                        cc.conflictDiagnostics(*g, pkgs)...)
+    if hasErrors(graphDiags) {
+      return errors.New("Conflicting namespace providers.")
     }
   } else {
-    jsFiles = append(jsFiles, srcPath)
+    jsFiles = append(jsFiles, srcName)
   }
 
+  // The renaming map of the stylesheet with the same target name should be
+  // seen before any call to goog.getCssName.
+  cssMap, _ := cc.Outputs.ReadFile(cc.getCssRenamingMapName(relOutPath))
+
+  srcHashes := cc.hashSources(srcName)
+  outPath := filepath.Join(tmpDir, "out" + cc.CompiledSuffix)
   if cc.UseClosureApi {
-    return cc.CompileWithClosureApi(jsFiles, nil, outPath)
+    var src []byte
+    src, err = cc.readSources(jsFiles)
+    if err != nil {
+      return err
+    }
+    diags, err = cc.compileCodeWithClosureApi(string(cssMap) + string(src),
+                                              outPath)
+  } else {
+    var localFiles []string
+    localFiles, err = cc.localSourcePaths(jsFiles, tmpDir)
+    if err != nil {
+      return err
+    }
+
+    names := make(map[string]string)
+    for i, localFile := range localFiles {
+      names[localFile] = jsFiles[i]
+    }
+
+    if cssMap != nil {
+      mapPath := filepath.Join(tmpDir, "cssmap" + cc.SourceSuffix)
+      err = ioutil.WriteFile(mapPath, cssMap, 0644)
+      if err != nil {
+        return err
+      }
+      localFiles = append([]string{mapPath}, localFiles...)
+    }
+
+    var stdErr string
+    stdErr, err = cc.runJava(cc.getCompilerArgs(localFiles, srcPkgs, outPath))
+    diags = parseJavaDiagnostics(stdErr, names)
+  }
+
+  if err != nil {
+    return err
+  }
+
+  err = cc.storeOutput(outName, outPath)
+  if err != nil {
+    return err
+  }
+
+  cc.recordSources(outName, srcHashes)
+  return cc.processOutput(outName)
+}
+
+// Returns the packages and their dependencies in Compiler.DependencyOrder.
+func (cc *Compiler) getDependencies(g *depgraph.DependencyGraph,
+                                    pkgs []string) ([]*depgraph.Node, error) {
+  nodes := []*depgraph.Node{}
+  for _, pkg := range pkgs {
+    node, ok := g.Nodes[pkg]
+    if !ok {
+      return nil, errors.New(fmt.Sprintf("Package %s not found in %s.", pkg,
+                                         cc.Root))
+    }
+    nodes = append(nodes, node)
   }
 
-  return cc.CompileWithClosureJar(jsFiles, srcPkgs, outPath)
+  order := cc.DependencyOrder
+  if order == "" {
+    order = depgraph.RequireOrder
+  }
+  return g.OrderedDependencies(nodes, order), nil
 }
 
 func (cc *Compiler) CompileWithClosureJar(jsFiles []string, entryPkgs []string,
                                           outPath string) error {
+  _, err := cc.runJava(cc.getCompilerArgs(jsFiles, entryPkgs, outPath))
+  return err
+}
+
+func (cc *Compiler) getCompilerArgs(jsFiles []string, entryPkgs []string,
+                                    outPath string) []string {
   args := []string{"-jar", cc.CompilerJarPath}
 
   for _, b := range cc.BaseFiles {
@@ -467,27 +830,34 @@ func (cc *Compiler) CompileWithClosureJar(jsFiles []string, entryPkgs []string,
     args = append(args, "--formatting", string(cc.Formatting))
   }
 
+  return args
+}
+
+// Runs java with the given arguments and pipes its output to the standard
+// output and error of this process. Returns what java writes on its standard
+// error.
+func (cc *Compiler) runJava(args []string) (string, error) {
   cmd := exec.Command("java", args...)
   stdErr, err := cmd.StderrPipe()
   if err != nil {
-    return errors.New("Cannot attach to stderr of the compiler.")
+    return "", errors.New("Cannot attach to stderr of the compiler.")
   }
 
   stdOut, err := cmd.StdoutPipe()
   if err != nil {
-    return errors.New("Cannot attach to stdout of the compiler.")
+    return "", errors.New("Cannot attach to stdout of the compiler.")
   }
 
   err = cmd.Start()
   if err != nil {
-    return errors.New("Cannot run the compiler.")
+    return "", errors.New("Cannot run the compiler.")
   }
 
-  io.Copy(os.Stderr, stdErr)
+  var errBuffer bytes.Buffer
+  io.Copy(io.MultiWriter(os.Stderr, &errBuffer), stdErr)
   io.Copy(os.Stdout, stdOut)
 
-  err = cmd.Wait()
-  return err
+  return errBuffer.String(), cmd.Wait()
 }
 
 func (cc *Compiler) CompileWithClosureApi(jsFiles []string, entryPkgs []string,
@@ -502,6 +872,15 @@ func (cc *Compiler) CompileWithClosureApi(jsFiles []string, entryPkgs []string,
     srcBuffer.Write(content)
   }
 
+  _, err := cc.compileCodeWithClosureApi(srcBuffer.String(), outPath)
+  return err
+}
+
+// Compiles the given JavaScript code using the closure REST API and writes the
+// result into outPath. Returns the errors and warnings reported by the API.
+func (cc *Compiler) compileCodeWithClosureApi(src string,
+                                              outPath string) ([]Diagnostic,
+                                                               error) {
   var extBuffer bytes.Buffer
   for _, file := range(cc.Externs) {
     content, err := ioutil.ReadFile(file)
@@ -511,18 +890,35 @@ func (cc *Compiler) CompileWithClosureApi(jsFiles []string, entryPkgs []string,
     extBuffer.Write(content)
   }
 
-  res, err := cc.dialClosureApi(srcBuffer.String(), extBuffer.String())
+  res, err := cc.dialClosureApi(src, extBuffer.String())
   if err != nil {
-    return err
+    return nil, err
+  }
+
+  diags := []Diagnostic{}
+  for _, cWarn := range(res.Warnings) {
+    diags = append(diags, Diagnostic{
+      Severity: SeverityWarning,
+      File: cWarn.File,
+      Line: cWarn.Lineno,
+      Column: cWarn.Charno,
+      Message: cWarn.Warning,
+    })
   }
 
   if len(res.Errors) != 0 {
     for _, cErr := range(res.Errors) {
       fmt.Fprintf(os.Stderr, "Compilation error: %s\n\t%s\n\t%s\n",
                   cErr.Error, cErr.Line, errAnchor(cErr.Charno))
-
+      diags = append(diags, Diagnostic{
+        Severity: SeverityError,
+        File: cErr.File,
+        Line: cErr.Lineno,
+        Column: cErr.Charno,
+        Message: cErr.Error,
+      })
     }
-    return errors.New("Compilation error.")
+    return diags, errors.New("Compilation error.")
   }
 
   if len(res.Warnings) != 0 {
@@ -534,7 +930,7 @@ func (cc *Compiler) CompileWithClosureApi(jsFiles []string, entryPkgs []string,
 
   ioutil.WriteFile(outPath, []byte(res.CompiledCode), 0644)
 
-  return nil
+  return diags, nil
 }
 
 func errAnchor(charNo int) string {
@@ -545,50 +941,105 @@ func errAnchor(charNo int) string {
   return strings.Repeat("-", int(indent)) + "^"
 }
 
-func concatContent(nodes []*depgraph.Node) string {
-  var buffer bytes.Buffer
-  for _, val := range nodes {
-    content, err := ioutil.ReadFile(val.Path)
-    if err != nil {
-      // TODO(soheil): Should we simply ignore such errors?
+// Scans the sources into a new dependency graph, and publishes it as the
+// current graph.
+func (cc *Compiler) reloadDependencyGraph() *depgraph.DependencyGraph {
+  start := time.Now()
+  g := depgraph.New()
+  if cc.IndexPath != "" && cc.scans != nil {
+    cc.scans.loadIndex(cc.IndexPath)
+  }
+
+  // Files in the order they are walked, which is the same for the same
+  // sources.
+  jobs := []scanJob{}
+  templates := []string{}
+  for _, root := range cc.sourceRoots() {
+    if len(root.DepsFiles) != 0 {
+      for _, dep := range readDepsFiles(root) {
+        jobs = append(jobs, scanJob{root: root, name: dep.name,
+                                    res: dep.scanResult, done: true})
+      }
       continue
     }
-    buffer.Write(content)
-  }
-  return buffer.String()
-}
 
-func (cc *Compiler) reloadDependencyGraph() {
-  // TODO(soheil): Here, we are loading the files twice. We can make it in one
-  //               pass.
-  filepath.Walk(cc.Root,
-                func(path string, info os.FileInfo, err error) error {
-                  if !cc.isSourceJavascript(path) {
-                    return nil
-                  }
+    cc.walkRoot(root, func(root SourceRoot, name string) {
+      if cc.isSoyTemplate(name) {
+        templates = append(templates, name)
+      }
 
-                  pkgs, err := getClosurePackage(path)
-                  if err != nil {
-                    return nil
-                  }
+      if cc.isSoyTemplate(name) || cc.isSourceJavascript(name) {
+        jobs = append(jobs, scanJob{root: root, name: name})
+      }
+    })
+  }
 
-                  for _, pkg := range pkgs {
-                    glog.V(1).Info("Found package ", pkg, " in ", path)
-                    cc.depg.AddFile(pkg, path)
-                  }
-                  return nil
-                })
+  workers := cc.ScanWorkers
+  if workers <= 0 {
+    workers = runtime.NumCPU()
+  }
+  cc.scanJobs(jobs, workers)
 
-  for _, node  := range cc.depg.Nodes {
-    deps, err := getClosureDependecies(node.Path)
-    if err != nil || deps == nil {
+  files := []string{}
+  scans := make(map[string]scanResult)
+  unchanged := 0
+  for _, job := range jobs {
+    if _, ok := scans[job.name]; ok || job.err != nil {
       continue
     }
-    for _, dep := range deps {
-      glog.V(1).Info("Found dependency from ", node.Pkg, " to ", dep)
-      cc.depg.AddDependency(node.Pkg, dep)
+    files = append(files, job.name)
+    scans[job.name] = job.res
+    if job.unchanged {
+      unchanged++
+    }
+
+    // Roots are walked in the order of priority, so the graph keeps the
+    // provider in the earlier root and records the rest as conflicts.
+    for _, pkg := range job.res.Provides {
+      glog.V(1).Info("Found package ", pkg, " in ", job.name)
+      g.AddFile(pkg, job.name)
     }
   }
+
+  if cc.IndexPath != "" && cc.scans != nil {
+    err := cc.scans.saveIndex(cc.IndexPath, cc.sourceRoots(), jobs)
+    if err != nil {
+      glog.Error("Cannot write the index ", cc.IndexPath, ": ", err)
+    }
+  }
+
+  cc.recordScan(ScanStatus{
+    Time: start,
+    Duration: time.Since(start),
+    Files: len(files),
+    Unchanged: unchanged,
+    Workers: workers,
+  })
+
+  for _, d := range cc.conflictDiagnostics(g, nil) {
+    glog.Warning(d)
+  }
+
+  // Requires are added in the order of the files, so the same sources break
+  // their cycles at the same requires.
+  for _, name := range files {
+    for _, pkg := range scans[name].Provides {
+      if node, ok := g.Nodes[pkg]; !ok || node.Path != name {
+        continue
+      }
+
+      for _, req := range scans[name].Requires {
+        glog.V(1).Info("Found dependency from ", pkg, " to ", req.Namespace)
+        err := g.AddEdge(pkg, req.Namespace, req.Kind, req.Line)
+        if cycleErr, ok := err.(*depgraph.CycleError); ok {
+          glog.Error(cycleErr)
+        }
+      }
+    }
+  }
+
+  cc.graph.store(&g, templates)
+  return &g
 }
 
 type ClosureApiResult struct {
@@ -661,6 +1112,8 @@ func (cc *Compiler) dialClosureApi(src string, ext string) (*ClosureApiResult,
 
 var closureProvideRegex *regexp.Regexp
 var closureRequireRegex *regexp.Regexp
+// Matches goog.require, goog.requireType and goog.forwardDeclare calls.
+var closureAnyRequireRegex *regexp.Regexp
 
 func init() {
   re, err := regexp.Compile(`goog.provide\(['"](.*)['"]\).*;`)
@@ -676,10 +1129,18 @@ func init() {
   }
 
   closureRequireRegex = re
+
+  re, err = regexp.Compile(
+      `goog.(require|requireType|forwardDeclare)\(['"](.*)['"]\).*;`)
+  if err != nil {
+    panic("Cannot compile closure require regex.")
+  }
+
+  closureAnyRequireRegex = re
 }
 
-func getClosurePackage(path string) ([]string, error) {
-  content, err := ioutil.ReadFile(path)
+func getClosurePackage(fsys fs.FS, name string) ([]string, error) {
+  content, err := fs.ReadFile(fsys, name)
   if err != nil {
     return nil, err
   }
@@ -696,8 +1157,8 @@ func getClosurePackage(path string) ([]string, error) {
   return pkgs, nil
 }
 
-func getClosureDependecies(path string) ([]string, error) {
-  content, err := ioutil.ReadFile(path)
+func getClosureDependecies(fsys fs.FS, name string) ([]string, error) {
+  content, err := fs.ReadFile(fsys, name)
   if err != nil {
     return nil, err
   }
@@ -15,11 +15,17 @@
 package glosure
 
 import (
+  "archive/zip"
+  "bytes"
   "flag"
   "fmt"
   "io/ioutil"
   "net/http"
+  "net/http/httptest"
+  "os"
+  "path/filepath"
   "testing"
+  "time"
 )
 
 func TestDialClosureApi(t *testing.T) {
@@ -35,7 +41,7 @@ func TestDialClosureApi(t *testing.T) {
 }
 
 func TestGetClosureDependecies(t *testing.T) {
-  deps, err := getClosureDependecies("./test_resources/pkg1.js")
+  deps, err := getClosureDependecies(DirSources("test_resources"), "pkg1.js")
   if err != nil {
     t.Error(err)
     return
@@ -46,7 +52,7 @@ func TestGetClosureDependecies(t *testing.T) {
 }
 
 func TestGetClosurePackage(t *testing.T) {
-  pkgs, err := getClosurePackage("./test_resources/pkg1.js")
+  pkgs, err := getClosurePackage(DirSources("test_resources"), "pkg1.js")
   if err != nil {
     t.Error(err)
     return
@@ -71,6 +77,43 @@ func TestCompilerJar(t *testing.T) {
   }
 }
 
+func TestDownloadCompilerJar(t *testing.T) {
+  var release bytes.Buffer
+  w := zip.NewWriter(&release)
+  f, _ := w.Create("compiler.jar")
+  f.Write([]byte("jar"))
+  w.Close()
+
+  server := httptest.NewServer(http.HandlerFunc(
+      func(res http.ResponseWriter, req *http.Request) {
+        res.Write(release.Bytes())
+      }))
+  defer server.Close()
+
+  defer func(url string) { compilerDownloadUrl = url }(compilerDownloadUrl)
+  compilerDownloadUrl = server.URL
+  cache := t.TempDir()
+  t.Setenv("XDG_CACHE_HOME", cache)
+  t.Setenv("HOME", cache)
+
+  // The jar is not written into the root, which might be read-only.
+  root := t.TempDir()
+  cc := NewCompiler(root)
+  jar, err := cc.downloadCompilerJar()
+  expected := filepath.Join(compilerCacheDir(), "__compiler__.jar")
+  if err != nil || jar != expected {
+    t.Fatal("Cannot download the compiler: ", jar, err)
+  }
+
+  if content, _ := ioutil.ReadFile(jar); string(content) != "jar" {
+    t.Error("Invalid downloaded jar: ", string(content))
+  }
+
+  if files, _ := ioutil.ReadDir(root); len(files) != 0 {
+    t.Error("Compiler is downloaded into the root: ", files)
+  }
+}
+
 func TestCompilerApi(t *testing.T) {
   cc := NewCompiler("./test_resources")
   cc.UseClosureApi = true
@@ -86,6 +129,90 @@ func TestCompilerApi(t *testing.T) {
   }
 }
 
+func TestServeETag(t *testing.T) {
+  cc := NewCompiler(newCompiledRoot(t))
+
+  res := httptest.NewRecorder()
+  req, _ := http.NewRequest("GET", "/app.min.js", nil)
+  ServeHttp(res, req, &cc)
+  etag := res.Header().Get("ETag")
+  if res.Code != http.StatusOK || etag == "" {
+    t.Fatal("No ETag for compiled output: ", res.Code)
+  }
+
+  if res.Header().Get("Cache-Control") != DefaultCachePolicy.Default {
+    t.Error("Invalid cache control: ", res.Header().Get("Cache-Control"))
+  }
+
+  res = httptest.NewRecorder()
+  req.Header.Set("If-None-Match", etag)
+  ServeHttp(res, req, &cc)
+  if res.Code != http.StatusNotModified {
+    t.Error("Expected 304 for a matching ETag, got ", res.Code)
+  }
+
+  res = httptest.NewRecorder()
+  req.Header.Set("Accept-Encoding", "gzip")
+  ServeHttp(res, req, &cc)
+  if res.Code != http.StatusOK || res.Header().Get("ETag") == etag {
+    t.Error("Gzip output is served with the same ETag: ", res.Code)
+  }
+}
+
+func TestDevCachePolicy(t *testing.T) {
+  cc := NewCompiler(newCompiledRoot(t))
+  cc.Debug()
+
+  res := httptest.NewRecorder()
+  req, _ := http.NewRequest("GET", cc.AssetPath("/app.min.js"), nil)
+  ServeHttp(res, req, &cc)
+  if res.Header().Get("Cache-Control") != "no-cache" {
+    t.Error("Fingerprinted output is cached in debug mode: ",
+            res.Header().Get("Cache-Control"))
+  }
+}
+
+func TestTouchedSourceIsNotStale(t *testing.T) {
+  root := newCompiledRoot(t)
+  cc := NewCompiler(root)
+  srcPath := filepath.Join(root, "app.js")
+  cc.recordSources("app.min.js", cc.hashSources("app.js"))
+
+  // Newer than the output, which is compiled an hour ago.
+  touched := time.Now().Add(-time.Minute)
+  os.Chtimes(srcPath, touched, touched)
+  if !cc.jsIsAlreadyCompiled("/app.min.js") {
+    t.Fatal("A touched source needs a compile.")
+  }
+
+  outStat, _ := cc.Outputs.Stat("app.min.js")
+  if !outStat.ModTime().Before(touched) {
+    t.Error("Checking the output touches it.")
+  }
+
+  // Serving the output revalidates the source, so it is not hashed again,
+  // and the output is not rewritten.
+  res := httptest.NewRecorder()
+  req, _ := http.NewRequest("GET", "/app.min.js", nil)
+  ServeHttp(res, req, &cc)
+  stat, _ := cc.Outputs.Stat("app.min.js")
+  if res.Code != http.StatusOK || !stat.ModTime().Equal(outStat.ModTime()) {
+    t.Error("Served output is rewritten: ", res.Code, stat.ModTime())
+  }
+
+  key := "app.min.js\x00app.js"
+  if revalidated := cc.manifest.revalidated[key]; !revalidated.Equal(touched) {
+    t.Error("Touched source is not revalidated: ", revalidated)
+  }
+
+  ioutil.WriteFile(srcPath, []byte("goog.provide('app2');\n"), 0644)
+  future := time.Now().Add(time.Hour)
+  os.Chtimes(srcPath, future, future)
+  if cc.jsIsAlreadyCompiled("/app.min.js") {
+    t.Error("A changed source does not need a compile.")
+  }
+}
+
 func Example() {
   // Parse the flags if you want to use glog.
   flag.Parse()
@@ -101,7 +228,7 @@ func Example() {
   // Use advanced optimizations.
   cc.CompilationLevel = AdvancedOptimizations
 
-  http.Handle("/", GlosureServer(cc))
+  http.Handle("/", GlosureServer(&cc))
   fmt.Println("Checkout http://localhost:8080/sample.min.js")
   http.ListenAndServe(":8080", nil);
 }
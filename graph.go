@@ -0,0 +1,122 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "bytes"
+  "errors"
+  "io"
+  "net/http"
+
+  "github.com/soheilhy/glosure/depgraph"
+)
+
+// Returns a copy of the dependency graph of the source roots. The graph is
+// loaded if it is not loaded yet. The copy belongs to the caller: changing it
+// does not change the graph used by the compiler.
+func (cc *Compiler) DependencyGraph() depgraph.DependencyGraph {
+  return cc.sharedGraph().Clone()
+}
+
+// Returns the current dependency graph, shared by all readers. It must not be
+// modified, and is safe to read from any goroutine without a lock.
+func (cc *Compiler) sharedGraph() *depgraph.DependencyGraph {
+  return cc.scannedSources().graph
+}
+
+// Returns the graph and the templates of the last scan. The sources are
+// scanned if they are not scanned yet.
+func (cc *Compiler) scannedSources() *scannedSources {
+  if scanned := cc.graph.loadSources(); scanned != nil {
+    return scanned
+  }
+
+  cc.graph.mutex.Lock()
+  defer cc.graph.mutex.Unlock()
+
+  if scanned := cc.graph.loadSources(); scanned == nil {
+    cc.reloadDependencyGraph()
+  }
+  return cc.graph.loadSources()
+}
+
+// Writes the dependency graph in the given format ("dot" or "json"). If
+// entries are given, only the subgraph reachable from them is written.
+func (cc *Compiler) ExportDependencyGraph(w io.Writer, format string,
+                                          entries ...string) error {
+  g := *cc.sharedGraph()
+  if len(entries) != 0 {
+    var err error
+    g, err = g.Subgraph(entries...)
+    if err != nil {
+      return err
+    }
+  }
+
+  switch format {
+  case "dot":
+    return g.WriteDot(w)
+  case "json", "":
+    return g.WriteJson(w)
+  }
+  return errors.New("Unknown graph format: " + format)
+}
+
+// Returns the entry points to rebuild when the given source files change:
+// the namespaces that nothing requires and that are provided by the files or
+// require them transitively. Files providing no namespace are ignored.
+func (cc *Compiler) AffectedEntryPoints(files ...string) ([]string, error) {
+  g := cc.sharedGraph()
+  pkgs := []string{}
+  for _, file := range files {
+    pkgs = append(pkgs, g.PackagesOfFile(cleanName(file))...)
+  }
+  return g.AffectedRoots(pkgs...)
+}
+
+// Returns the chains of requires through which entry depends on target,
+// shortest first, with the file and line of every require. At most limit
+// chains are returned, or all of them if limit is 0. The result is empty if
+// entry does not depend on target.
+func (cc *Compiler) RequirePaths(entry string, target string,
+                                 limit int) ([]depgraph.RequirePath, error) {
+  return cc.sharedGraph().AllPaths(entry, target, limit)
+}
+
+// Creates an http.Handler serving the dependency graph of the compiler as
+// JSON, or as DOT if the request has "format=dot". Pass "entry" parameters to
+// get the subgraph reachable from those namespaces (e.g.,
+// "?format=dot&entry=app").
+func GraphServer(cc *Compiler) http.Handler {
+  return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+    query := req.URL.Query()
+    format := query.Get("format")
+
+    var buf bytes.Buffer
+    err := cc.ExportDependencyGraph(&buf, format, query["entry"]...)
+    if err != nil {
+      http.Error(res, err.Error(), http.StatusBadRequest)
+      return
+    }
+
+    if format == "dot" {
+      res.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+    } else {
+      res.Header().Set("Content-Type", "application/json")
+    }
+    res.Header().Set("Cache-Control", "no-cache")
+    res.Write(buf.Bytes())
+  })
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "bytes"
+  "fmt"
+  "net/http"
+  "net/http/httptest"
+  "reflect"
+  "strings"
+  "testing"
+  "testing/fstest"
+
+  "github.com/soheilhy/glosure/depgraph"
+)
+
+func TestGraphServer(t *testing.T) {
+  cc := NewCompilerWithSources(newMapSources())
+  handler := GraphServer(cc)
+
+  req := httptest.NewRequest("GET", "/graph?format=dot&entry=pkg2", nil)
+  res := httptest.NewRecorder()
+  handler.ServeHTTP(res, req)
+  body := res.Body.String()
+  if !strings.Contains(body, `"pkg2" -> "pkg3";`) ||
+     strings.Contains(body, "pkg1") {
+    t.Error("Invalid DOT graph: ", body)
+  }
+
+  req = httptest.NewRequest("GET", "/graph", nil)
+  res = httptest.NewRecorder()
+  handler.ServeHTTP(res, req)
+  if !strings.Contains(res.Body.String(), `"file": "lib/pkg1.js"`) {
+    t.Error("Invalid JSON graph: ", res.Body.String())
+  }
+
+  req = httptest.NewRequest("GET", "/graph?entry=missing", nil)
+  res = httptest.NewRecorder()
+  handler.ServeHTTP(res, req)
+  if res.Code != http.StatusBadRequest {
+    t.Error("Graph of a missing entry is served: ", res.Code)
+  }
+}
+
+func TestAffectedEntryPoints(t *testing.T) {
+  cc := NewCompilerWithSources(newMapSources())
+  affected, err := cc.AffectedEntryPoints("lib/pkg3.js", "lib/missing.js")
+  if err != nil || !reflect.DeepEqual(affected, []string{"pkg1"}) {
+    t.Error("Invalid affected entry points: ", affected, err)
+  }
+
+  affected, err = cc.AffectedEntryPoints("lib/missing.js")
+  if err != nil || len(affected) != 0 {
+    t.Error("Entry points are affected by a missing file: ", affected, err)
+  }
+}
+
+func TestRequirePaths(t *testing.T) {
+  cc := NewCompilerWithSources(newMapSources())
+  paths, err := cc.RequirePaths("pkg1", "pkg3", 1)
+  if err != nil || len(paths) != 1 ||
+     paths[0].String() != "pkg1 (lib/pkg1.js:5) -> pkg3 (lib/pkg3.js)" {
+    t.Error("Invalid shortest path: ", paths, err)
+  }
+
+  paths, err = cc.RequirePaths("pkg1", "pkg3", 0)
+  if err != nil || len(paths) != 2 || paths[1].String() !=
+     "pkg1 (lib/pkg1.js:4) -> pkg2 (lib/pkg2.js:3) -> pkg3 (lib/pkg3.js)" {
+    t.Error("Invalid paths: ", paths, err)
+  }
+
+  paths, err = cc.RequirePaths("pkg3", "pkg1", 1)
+  if err != nil || len(paths) != 0 {
+    t.Error("Path against the requires: ", paths, err)
+  }
+}
+
+// Builds the same sources in new compilers, and checks that the inputs of the
+// compiler are the same bytes every time.
+func TestDeterministicDependencies(t *testing.T) {
+  sources := fstest.MapFS{}
+  for i := 0; i < 30; i++ {
+    src := fmt.Sprintf("goog.provide('pkg%d');\n", i)
+    for j := i + 1; j < 30; j += 7 {
+      src += fmt.Sprintf("goog.require('pkg%d');\n", j)
+    }
+    // pkg29 requires pkg0, which closes cycles.
+    if i == 29 {
+      src += "goog.require('pkg0');\n"
+    }
+    sources[fmt.Sprintf("lib/%02d.js", (i * 11) % 30)] =
+        &fstest.MapFile{Data: []byte(src)}
+  }
+
+  for _, order := range []depgraph.Order{depgraph.RequireOrder,
+                                         depgraph.NamespaceOrder} {
+    var expected []byte
+    for i := 0; i < 20; i++ {
+      cc := NewCompilerWithSources(sources)
+      cc.DependencyOrder = order
+      deps, err := cc.getDependencies(cc.reloadDependencyGraph(),
+                                      []string{"pkg0"})
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      files := []string{}
+      for _, dep := range deps {
+        files = append(files, dep.Path)
+      }
+
+      src, err := cc.readSources(files)
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      if expected == nil {
+        expected = src
+      } else if !bytes.Equal(src, expected) {
+        t.Fatal("Inputs differ between builds: ", order, files)
+      }
+    }
+  }
+}
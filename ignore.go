@@ -0,0 +1,305 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "io/fs"
+  "path"
+  "path/filepath"
+  "regexp"
+  "strings"
+
+  "github.com/golang/glog"
+)
+
+// Name of the files listing the paths that are not scanned in a directory and
+// its subdirectories, in gitignore syntax.
+const IgnoreFileName = ".glosureignore"
+
+// Source files that are not scanned by default: hidden files and directories,
+// node_modules, and the downloaded compiler. Compiled outputs (files ending
+// with Compiler.CompiledSuffix) are never scanned.
+var DefaultSourceExcludes = []string{
+  ".*",
+  "node_modules/",
+  "__compiler__.*",
+}
+
+// A gitignore pattern.
+type ignorePattern struct {
+  regex *regexp.Regexp
+  // Whether the pattern starts with "!" and includes what it matches again.
+  negate bool
+  // Whether the pattern ends with "/" and only matches directories.
+  dirOnly bool
+}
+
+// Compiles a gitignore pattern relative to the base directory. Returns false
+// for blank lines and comments.
+func parseIgnorePattern(line string, base string) (ignorePattern, bool) {
+  line = strings.TrimRight(line, " \t\r")
+  if line == "" || strings.HasPrefix(line, "#") {
+    return ignorePattern{}, false
+  }
+
+  p := ignorePattern{}
+  if strings.HasPrefix(line, "!") {
+    p.negate = true
+    line = line[1:]
+  } else if strings.HasPrefix(line, "\\") {
+    line = line[1:]
+  }
+
+  if strings.HasSuffix(line, "/") {
+    p.dirOnly = true
+    line = strings.TrimRight(line, "/")
+  }
+
+  // Patterns without a slash match at any depth, and the rest are relative to
+  // the base.
+  if !strings.Contains(line, "/") {
+    line = "**/" + line
+  }
+  line = strings.TrimPrefix(line, "/")
+
+  var re strings.Builder
+  re.WriteString("^")
+  if base != "" && base != "." {
+    re.WriteString(regexp.QuoteMeta(base + "/"))
+  }
+
+  for i := 0; i < len(line); i++ {
+    switch {
+    case strings.HasPrefix(line[i:], "**/"):
+      re.WriteString("(?:.*/)?")
+      i += 2
+    case strings.HasPrefix(line[i:], "/**") && i + 3 == len(line):
+      re.WriteString("/.*")
+      i += 2
+    case strings.HasPrefix(line[i:], "**"):
+      re.WriteString(".*")
+      i++
+    case line[i] == '*':
+      re.WriteString("[^/]*")
+    case line[i] == '?':
+      re.WriteString("[^/]")
+    case line[i] == '[':
+      end := strings.IndexByte(line[i:], ']')
+      if end < 0 {
+        re.WriteString(`\[`)
+        continue
+      }
+
+      class := line[i + 1:i + end]
+      if strings.HasPrefix(class, "!") {
+        class = "^" + class[1:]
+      }
+      re.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+      i += end
+    default:
+      re.WriteString(regexp.QuoteMeta(line[i:i + 1]))
+    }
+  }
+  re.WriteString("$")
+
+  regex, err := regexp.Compile(re.String())
+  if err != nil {
+    glog.Warning("Invalid ignore pattern ", line, ": ", err)
+    return ignorePattern{}, false
+  }
+  p.regex = regex
+  return p, true
+}
+
+// Compiles the patterns in the lines of a gitignore file.
+func parseIgnorePatterns(lines []string, base string) []ignorePattern {
+  patterns := []ignorePattern{}
+  for _, line := range lines {
+    if p, ok := parseIgnorePattern(line, base); ok {
+      patterns = append(patterns, p)
+    }
+  }
+  return patterns
+}
+
+// Returns whether the patterns ignore name, and whether any of them matches.
+// The last matching pattern wins.
+func matchIgnorePatterns(patterns []ignorePattern, name string,
+                         isDir bool) (ignored bool, matched bool) {
+  for _, p := range patterns {
+    if (!p.dirOnly || isDir) && p.regex.MatchString(name) {
+      ignored = !p.negate
+      matched = true
+    }
+  }
+  return
+}
+
+// Returns the directory of the outputs of the compiler in the root, relative
+// to the root, or "" if the outputs are not in a subdirectory of the root
+// (e.g., the outputs of a configuration in a Registry).
+func (cc *Compiler) outputDir(root SourceRoot) string {
+  d, ok := root.Sources.(interface{ Dir() string })
+  if !ok {
+    return ""
+  }
+
+  prefix := ""
+  store := cc.Outputs
+  for {
+    p, ok := store.(prefixedStore)
+    if !ok {
+      break
+    }
+    prefix = path.Join(p.prefix, prefix)
+    store = p.store
+  }
+
+  dirStore, ok := store.(*DirStore)
+  if !ok {
+    return ""
+  }
+
+  sourceDir, err := filepath.Abs(d.Dir())
+  if err != nil {
+    return ""
+  }
+
+  outDir, err := filepath.Abs(filepath.Join(dirStore.Dir,
+                                            filepath.FromSlash(prefix)))
+  if err != nil {
+    return ""
+  }
+
+  rel, err := filepath.Rel(sourceDir, outDir)
+  if err != nil || rel == "." || rel == ".." ||
+     strings.HasPrefix(rel, ".." + string(filepath.Separator)) {
+    return ""
+  }
+  return filepath.ToSlash(rel)
+}
+
+// Returns the directories of compiled outputs in the root, relative to the
+// root: the outputs of the compiler and, in a Registry, of every other
+// configuration.
+func (cc *Compiler) outputDirs(root SourceRoot) map[string]bool {
+  compilers := []*Compiler{cc}
+  if cc.registry != nil {
+    compilers = cc.registry.compilers()
+  }
+
+  dirs := make(map[string]bool)
+  for _, c := range compilers {
+    if dir := c.outputDir(root); dir != "" {
+      dirs[dir] = true
+    }
+  }
+  return dirs
+}
+
+// Calls fn for every file in the root that is not ignored by the
+// .glosureignore files of the root, Compiler.SourceExcludes and
+// Compiler.SourceIncludes. Symbolic links to directories are followed once,
+// so links back to a parent directory do not loop. Compiled outputs in the
+// root are never walked (see Compiler.outputDirs).
+func (cc *Compiler) walkRoot(root SourceRoot,
+                             fn func(root SourceRoot, name string)) {
+  outDirs := cc.outputDirs(root)
+  excludes := parseIgnorePatterns(cc.SourceExcludes, "")
+  if cc.CompiledSuffix != "" {
+    excludes = append(excludes,
+                      parseIgnorePatterns([]string{"*" + cc.CompiledSuffix},
+                                          "")...)
+  }
+  includes := parseIgnorePatterns(cc.SourceIncludes, "")
+  d, isDirSources := root.Sources.(interface{ Dir() string })
+  // Real paths of the walked directories.
+  walked := make(map[string]bool)
+
+  // Returns why a file or directory is skipped, or "" if it is not.
+  skipReason := func(rules []ignorePattern, rel string, isDir bool) string {
+    if isDir && outDirs[rel] {
+      return "output directory"
+    }
+
+    if ignored, _ := matchIgnorePatterns(rules, rel, isDir); ignored {
+      return "ignored by " + IgnoreFileName
+    }
+
+    name := root.Prefix + rel
+    if ignored, _ := matchIgnorePatterns(excludes, name, isDir); ignored {
+      return "excluded"
+    }
+
+    if !isDir && len(includes) != 0 {
+      if _, matched := matchIgnorePatterns(includes, name, false); !matched {
+        return "not included"
+      }
+    }
+    return ""
+  }
+
+  var walk func(dir string, rules []ignorePattern)
+  walk = func(dir string, rules []ignorePattern) {
+    if isDirSources {
+      real, err := filepath.EvalSymlinks(filepath.Join(d.Dir(),
+                                                       filepath.FromSlash(dir)))
+      if err == nil && walked[real] {
+        glog.V(1).Info("Skipping ", root.Prefix + dir, ": ", real,
+                       " is already walked")
+        return
+      }
+      walked[real] = true
+    }
+
+    ignoreFile := path.Join(dir, IgnoreFileName)
+    if content, err := fs.ReadFile(root.Sources, ignoreFile); err == nil {
+      lines := strings.Split(string(content), "\n")
+      rules = append(rules[:len(rules):len(rules)],
+                     parseIgnorePatterns(lines, dir)...)
+    }
+
+    entries, err := fs.ReadDir(root.Sources, dir)
+    if err != nil {
+      glog.Warning("Cannot walk ", dir, " in root ", root.Name, ": ", err)
+      return
+    }
+
+    for _, e := range entries {
+      rel := path.Join(dir, e.Name())
+      isDir := e.IsDir()
+      if e.Type() & fs.ModeSymlink != 0 {
+        info, err := fs.Stat(root.Sources, rel)
+        if err != nil {
+          glog.V(1).Info("Skipping ", root.Prefix + rel, ": ", err)
+          continue
+        }
+        isDir = info.IsDir()
+      }
+
+      if reason := skipReason(rules, rel, isDir); reason != "" {
+        glog.V(1).Info("Skipping ", root.Prefix + rel, ": ", reason)
+        continue
+      }
+
+      if isDir {
+        walk(rel, rules)
+      } else {
+        fn(root, root.Prefix + rel)
+      }
+    }
+  }
+  walk(".", nil)
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+  "testing/fstest"
+)
+
+func TestIgnorePatterns(t *testing.T) {
+  patterns := [...]struct {
+    Pattern string
+    Base string
+    Name string
+    IsDir bool
+    Ignored bool
+  }{
+    {"*.min.js", "", "a/b/app.min.js", false, true},
+    {"*.min.js", "", "a/b/app.js", false, false},
+    {"/build", "", "build", true, true},
+    {"/build", "", "a/build", true, false},
+    {"fixtures/", "", "a/fixtures", true, true},
+    {"fixtures/", "", "a/fixtures", false, false},
+    {"a/**/test", "", "a/b/c/test", true, true},
+    {"a/**/test", "", "a/test", true, true},
+    {"gen/**", "", "gen/x/y.js", false, true},
+    {"*.js", "lib", "lib/x.js", false, true},
+    {"*.js", "lib", "x.js", false, false},
+    {"/x.js", "lib", "lib/x.js", false, true},
+    {"file?.js", "", "file1.js", false, true},
+    {"file[!0-9].js", "", "file1.js", false, false},
+    {"# comment", "", "# comment", false, false},
+  }
+
+  for _, p := range patterns {
+    ignored, _ := matchIgnorePatterns(
+        parseIgnorePatterns([]string{p.Pattern}, p.Base), p.Name, p.IsDir)
+    if ignored != p.Ignored {
+      t.Error("Invalid match of ", p.Pattern, " in ", p.Base, " for ",
+              p.Name, ": ", ignored)
+    }
+  }
+
+  rules := parseIgnorePatterns([]string{"*.js", "!keep.js"}, "")
+  if ignored, matched := matchIgnorePatterns(rules, "keep.js", false);
+     ignored || !matched {
+    t.Error("Negated pattern does not include the file again.")
+  }
+}
+
+func walkedNames(cc *Compiler, root SourceRoot) string {
+  names := []string{}
+  cc.walkRoot(root, func(root SourceRoot, name string) {
+    names = append(names, name)
+  })
+  return strings.Join(names, " ")
+}
+
+func TestWalkRootIgnores(t *testing.T) {
+  file := &fstest.MapFile{Data: []byte("goog.provide('x');")}
+  cc := NewCompilerWithSources(fstest.MapFS{
+    ".glosureignore": {Data: []byte("# Test fixtures.\nfixtures/\n")},
+    ".hidden/a.js": file,
+    "app.js": file,
+    "app.min.js": file,
+    "fixtures/f.js": file,
+    "lib/.glosureignore": {Data: []byte("*_test.js\n!keep_test.js\n")},
+    "lib/lib.js": file,
+    "lib/lib_test.js": file,
+    "lib/keep_test.js": file,
+    "node_modules/m/m.js": file,
+    "other/fixtures.js": file,
+  })
+
+  root := cc.sourceRoots()[0]
+  expected := "app.js lib/keep_test.js lib/lib.js other/fixtures.js"
+  if names := walkedNames(cc, root); names != expected {
+    t.Error("Invalid walked files: ", names)
+  }
+
+  cc.SourceExcludes = append(cc.SourceExcludes, "other/")
+  cc.SourceIncludes = []string{"lib/**"}
+  if names := walkedNames(cc, root); names != "lib/keep_test.js lib/lib.js" {
+    t.Error("Invalid walked files with globs: ", names)
+  }
+
+  g := cc.DependencyGraph()
+  if g.Nodes["x"].Path != "lib/keep_test.js" {
+    t.Error("Ignored files are scanned: ", g.Nodes["x"])
+  }
+}
+
+func TestWalkRootSkipsCompiledOutputs(t *testing.T) {
+  file := &fstest.MapFile{Data: []byte("goog.provide('x');")}
+  cc := NewCompilerWithSources(fstest.MapFS{
+    "app.js": file,
+    "app.min.js": file,
+    "app.out.js": file,
+  })
+  cc.CompiledSuffix = ".out.js"
+
+  root := cc.sourceRoots()[0]
+  if names := walkedNames(cc, root); names != "app.js app.min.js" {
+    t.Error("Invalid walked files: ", names)
+  }
+}
+
+func TestWalkRootSymlinks(t *testing.T) {
+  dir := t.TempDir()
+  os.MkdirAll(filepath.Join(dir, "a", "b"), 0755)
+  ioutil.WriteFile(filepath.Join(dir, "a", "b", "b.js"), nil, 0644)
+  ioutil.WriteFile(filepath.Join(dir, "c.js"), nil, 0644)
+  // A loop back to the root, and a second path to a/b.
+  if err := os.Symlink("../..", filepath.Join(dir, "a", "b", "loop"));
+     err != nil {
+    t.Skip("Symbolic links are not supported: ", err)
+  }
+  os.Symlink("a/b", filepath.Join(dir, "link"))
+
+  // Links to directories out of the root are followed.
+  external := t.TempDir()
+  ioutil.WriteFile(filepath.Join(external, "e.js"), nil, 0644)
+  os.Symlink(external, filepath.Join(dir, "external"))
+
+  cc := NewCompiler(dir)
+  names := walkedNames(&cc, cc.sourceRoots()[0])
+  if names != "a/b/b.js c.js external/e.js" {
+    t.Error("Invalid walked files: ", names)
+  }
+}
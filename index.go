@@ -0,0 +1,178 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "bytes"
+  "encoding/json"
+  "io/ioutil"
+  "os"
+  "strings"
+  "time"
+
+  "github.com/golang/glog"
+)
+
+// Version of the index format. Indexes of other versions are ignored, and
+// rewritten after the next scan.
+const indexVersion = 1
+
+// Index of the scanned source files, kept in Compiler.IndexPath between
+// processes.
+type sourceIndex struct {
+  Version int `json:"version"`
+  Files []indexedFile `json:"files"`
+}
+
+type indexedFile struct {
+  // Absolute path of the source directory, and the name of the file in it.
+  Root string `json:"root"`
+  Name string `json:"name"`
+  scanResult
+  ModTime time.Time `json:"modTime"`
+  Size int64 `json:"size"`
+  // SHA-256 of the content, in hex.
+  Hash string `json:"hash"`
+}
+
+// Only trees in a directory have keys that are the same in every process.
+const indexedSourcesPrefix = "dir:"
+
+// Loads the index at path into the scan cache, unless it is already loaded.
+// Entries already in the cache are kept. A missing or invalid index is
+// ignored, and the files are scanned again.
+func (c *scanCache) loadIndex(path string) {
+  c.mutex.Lock()
+  defer c.mutex.Unlock()
+
+  if _, ok := c.indexes[path]; ok {
+    return
+  }
+  c.indexes[path] = nil
+
+  content, err := ioutil.ReadFile(path)
+  if err != nil {
+    if !os.IsNotExist(err) {
+      glog.Warning("Cannot read the index ", path, ": ", err)
+    }
+    return
+  }
+
+  var index sourceIndex
+  if err := json.Unmarshal(content, &index); err != nil {
+    glog.Warning("Ignoring the invalid index ", path, ": ", err)
+    return
+  }
+
+  if index.Version != indexVersion {
+    glog.Warningf("Ignoring the index %s of version %d (expected %d).", path,
+                  index.Version, indexVersion)
+    return
+  }
+
+  for _, f := range index.Files {
+    key := indexedSourcesPrefix + f.Root + "\x00" + f.Name
+    if _, ok := c.entries[key]; !ok {
+      c.entries[key] = scanEntry{f.scanResult, f.ModTime, f.Size, f.Hash}
+    }
+  }
+
+  c.indexes[path] = content
+  glog.Infof("Loaded %d files from the index %s.", len(index.Files), path)
+}
+
+// Returns the absolute paths of the directories among roots whose files are
+// scanned, rather than read from deps files.
+func indexedRoots(roots []SourceRoot) map[string]bool {
+  dirs := make(map[string]bool)
+  for _, root := range roots {
+    key := sourcesKey(root.Sources)
+    if len(root.DepsFiles) == 0 &&
+       strings.HasPrefix(key, indexedSourcesPrefix) {
+      dirs[key[len(indexedSourcesPrefix):]] = true
+    }
+  }
+  return dirs
+}
+
+// Writes the scans of the files scanned in jobs into the index at path.
+// Entries of the index for directories other than roots, written by other
+// compilers on the same index, are kept. The index is not written if it has
+// not changed.
+func (c *scanCache) saveIndex(path string, roots []SourceRoot,
+                              jobs []scanJob) error {
+  index := sourceIndex{Version: indexVersion, Files: []indexedFile{}}
+
+  c.mutex.Lock()
+  for _, job := range jobs {
+    key := sourcesKey(job.root.Sources)
+    if !job.source || job.err != nil ||
+       !strings.HasPrefix(key, indexedSourcesPrefix) {
+      continue
+    }
+
+    root := key[len(indexedSourcesPrefix):]
+    name := job.name[len(job.root.Prefix):]
+    entry, ok := c.entries[key + "\x00" + name]
+    if !ok || entry.hash == "" {
+      continue
+    }
+
+    index.Files = append(index.Files, indexedFile{
+      Root: root,
+      Name: name,
+      scanResult: entry.scanResult,
+      ModTime: entry.modTime,
+      Size: entry.size,
+      Hash: entry.hash,
+    })
+  }
+  c.mutex.Unlock()
+
+  last, err := ioutil.ReadFile(path)
+  if err != nil && !os.IsNotExist(err) {
+    return err
+  }
+
+  var existing sourceIndex
+  if json.Unmarshal(last, &existing) == nil &&
+     existing.Version == indexVersion {
+    owned := indexedRoots(roots)
+    for _, f := range existing.Files {
+      if !owned[f.Root] {
+        index.Files = append(index.Files, f)
+      }
+    }
+  }
+
+  content, err := json.Marshal(index)
+  if err != nil {
+    return err
+  }
+
+  if bytes.Equal(content, last) {
+    return nil
+  }
+
+  err = writeFileAtomically(path, content)
+  if err != nil {
+    return err
+  }
+
+  c.mutex.Lock()
+  c.indexes[path] = content
+  c.mutex.Unlock()
+  return nil
+}
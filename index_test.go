@@ -0,0 +1,175 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "encoding/json"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "testing"
+  "time"
+)
+
+func newIndexedCompiler(t *testing.T) (*Compiler, string) {
+  dir := t.TempDir()
+  files := map[string]string{
+    "a.js": "goog.provide('a');\ngoog.require('b');\n",
+    "b.js": "goog.provide('b');\n",
+    "m.js": "goog.module('m');\n",
+  }
+  for name, src := range files {
+    err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644)
+    if err != nil {
+      t.Fatal(err)
+    }
+  }
+
+  cc := NewCompiler(dir)
+  cc.IndexPath = filepath.Join(t.TempDir(), "index.json")
+  return &cc, dir
+}
+
+// Returns a compiler with the same sources and index, and an empty scan
+// cache, as in a new process.
+func restartCompiler(cc *Compiler) *Compiler {
+  restarted := NewCompiler(cc.Root)
+  restarted.IndexPath = cc.IndexPath
+  return &restarted
+}
+
+func readIndex(t *testing.T, path string) sourceIndex {
+  content, err := ioutil.ReadFile(path)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  var index sourceIndex
+  if err := json.Unmarshal(content, &index); err != nil {
+    t.Fatal(err)
+  }
+  return index
+}
+
+func TestIndex(t *testing.T) {
+  cc, dir := newIndexedCompiler(t)
+  cc.reloadDependencyGraph()
+
+  index := readIndex(t, cc.IndexPath)
+  if index.Version != indexVersion || len(index.Files) != 3 {
+    t.Fatal("Invalid index: ", index)
+  }
+
+  abs, _ := filepath.Abs(dir)
+  a := index.Files[0]
+  if a.Root != abs || a.Name != "a.js" || a.Module != provideModule ||
+     len(a.Requires) != 1 || a.Requires[0].Namespace != "b" ||
+     a.Hash == "" || a.Size == 0 || index.Files[2].Module != googModule {
+    t.Error("Invalid indexed files: ", index.Files)
+  }
+
+  cc = restartCompiler(cc)
+  g := cc.reloadDependencyGraph()
+  if scan := cc.Status().LastScan; scan.Files != 3 || scan.Unchanged != 3 {
+    t.Error("Indexed files are scanned again: ", scan)
+  }
+
+  if deps := g.GetDependenciesOfPackage("a"); len(deps) != 2 {
+    t.Error("Invalid dependencies: ", deps)
+  }
+}
+
+func TestIndexRevalidation(t *testing.T) {
+  cc, dir := newIndexedCompiler(t)
+  cc.reloadDependencyGraph()
+
+  // A touched file is read, but not scanned again.
+  later := time.Now().Add(time.Hour)
+  if err := os.Chtimes(filepath.Join(dir, "b.js"), later, later); err != nil {
+    t.Fatal(err)
+  }
+
+  src := "goog.provide('a');\n"
+  err := ioutil.WriteFile(filepath.Join(dir, "a.js"), []byte(src), 0644)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  cc = restartCompiler(cc)
+  g := cc.reloadDependencyGraph()
+  if scan := cc.Status().LastScan; scan.Unchanged != 2 {
+    t.Error("Invalid scan: ", scan)
+  }
+
+  if deps := g.GetDependenciesOfPackage("a"); len(deps) != 1 {
+    t.Error("Changed file is not scanned again: ", deps)
+  }
+
+  index := readIndex(t, cc.IndexPath)
+  if !index.Files[1].ModTime.Equal(later) ||
+     len(index.Files[0].Requires) != 0 {
+    t.Error("Index is not updated: ", index.Files)
+  }
+}
+
+func TestIndexVersion(t *testing.T) {
+  cc, _ := newIndexedCompiler(t)
+  cc.reloadDependencyGraph()
+
+  index := readIndex(t, cc.IndexPath)
+  index.Version = indexVersion + 1
+  content, _ := json.Marshal(index)
+  if err := ioutil.WriteFile(cc.IndexPath, content, 0644); err != nil {
+    t.Fatal(err)
+  }
+
+  cc = restartCompiler(cc)
+  cc.reloadDependencyGraph()
+  if scan := cc.Status().LastScan; scan.Unchanged != 0 {
+    t.Error("Index of another version is used: ", scan)
+  }
+
+  if index = readIndex(t, cc.IndexPath); index.Version != indexVersion {
+    t.Error("Index is not rewritten: ", index.Version)
+  }
+}
+
+func TestIndexKeepsOtherRoots(t *testing.T) {
+  cc, _ := newIndexedCompiler(t)
+  cc.reloadDependencyGraph()
+
+  // Another compiler with another root on the same index.
+  other := NewCompiler(t.TempDir())
+  other.IndexPath = cc.IndexPath
+  err := ioutil.WriteFile(filepath.Join(other.Root, "o.js"),
+                          []byte("goog.provide('o');\n"), 0644)
+  if err != nil {
+    t.Fatal(err)
+  }
+  other.reloadDependencyGraph()
+
+  if index := readIndex(t, cc.IndexPath); len(index.Files) != 4 {
+    t.Fatal("Entries of another root are dropped: ", index.Files)
+  }
+
+  // Entries of the compiler's own roots are replaced.
+  os.Remove(filepath.Join(cc.Root, "m.js"))
+  cc = restartCompiler(cc)
+  cc.reloadDependencyGraph()
+  index := readIndex(t, cc.IndexPath)
+  if len(index.Files) != 3 || index.Files[2].Name != "o.js" {
+    t.Error("Invalid merged index: ", index.Files)
+  }
+}
@@ -0,0 +1,326 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "bytes"
+  "fmt"
+  "io/fs"
+  "regexp"
+  "sort"
+  "strings"
+
+  "github.com/soheilhy/glosure/depgraph"
+)
+
+// Lint checks reported in Diagnostic.Check.
+const (
+  // A require of a namespace that no file provides.
+  CheckMissingProvide = "missing-provide"
+  // A require of a namespace that the file never references.
+  CheckUnusedRequire = "unused-require"
+  // A reference to a namespace that the file does not require.
+  CheckMissingRequire = "missing-require"
+  // Requires that are not sorted.
+  CheckUnsortedRequires = "unsorted-requires"
+)
+
+// Replaces comments and string literals with spaces, keeping the newlines so
+// that offsets map to the same lines.
+func stripCommentsAndStrings(src string) string {
+  out := []byte(src)
+  blank := func(from int, to int) {
+    for i := from; i < to && i < len(out); i++ {
+      if out[i] != '\n' {
+        out[i] = ' '
+      }
+    }
+  }
+
+  for i := 0; i < len(src); i++ {
+    switch {
+    case strings.HasPrefix(src[i:], "//"):
+      end := strings.IndexByte(src[i:], '\n')
+      if end < 0 {
+        end = len(src) - i
+      }
+      blank(i, i + end)
+      i += end
+    case strings.HasPrefix(src[i:], "/*"):
+      end := strings.Index(src[i + 2:], "*/")
+      if end < 0 {
+        end = len(src) - i - 4
+      }
+      blank(i, i + end + 4)
+      i += end + 3
+    case src[i] == '\'' || src[i] == '"' || src[i] == '`':
+      quote := src[i]
+      j := i + 1
+      for ; j < len(src) && src[j] != quote; j++ {
+        if src[j] == '\\' {
+          j++
+        } else if src[j] == '\n' && quote != '`' {
+          break
+        }
+      }
+      blank(i, j + 1)
+      i = j
+    }
+  }
+  return string(out)
+}
+
+var dottedNameRegex = regexp.MustCompile(
+    `[A-Za-z_$][\w$]*(?:\.[A-Za-z_$][\w$]*)+`)
+
+var requireStatementRegex = regexp.MustCompile(
+    `goog\.(?:require|requireType|forwardDeclare|provide)\s*\(\s*\)`)
+
+// Returns the namespaces of the dependency graph.
+func graphNamespaces(g depgraph.DependencyGraph) map[string]bool {
+  namespaces := make(map[string]bool, len(g.Nodes))
+  for pkg := range g.Nodes {
+    namespaces[pkg] = true
+  }
+  return namespaces
+}
+
+// Matches a require bound to a name or destructured (e.g.,
+// "const dom = goog.require('goog.dom');" or
+// "const {assert, fail: f} = goog.require('goog.asserts');").
+var requireAliasRegex = regexp.MustCompile(
+    `(?:const|let|var)\s+(\{[^}]*\}|[A-Za-z_$][\w$]*)\s*=\s*` +
+    `goog\.(?:require|requireType)\(\s*['"]([^'"]+)['"]\s*\)`)
+
+var identifierRegex = regexp.MustCompile(`[A-Za-z_$][\w$]*`)
+
+// Returns the required namespaces whose aliases are used in a file, as in
+// goog.modules. An alias is used if it appears anywhere but its declaration.
+func usedRequireAliases(src string) map[string]bool {
+  aliases := make(map[string][]string)
+  for _, m := range requireAliasRegex.FindAllStringSubmatch(src, -1) {
+    if !strings.HasPrefix(m[1], "{") {
+      aliases[m[2]] = append(aliases[m[2]], m[1])
+      continue
+    }
+
+    // A destructured name is bound to the part after its colon, if any.
+    for _, part := range strings.Split(strings.Trim(m[1], "{}"), ",") {
+      if colon := strings.Index(part, ":"); colon >= 0 {
+        part = part[colon + 1:]
+      }
+      if name := strings.TrimSpace(part); name != "" {
+        aliases[m[2]] = append(aliases[m[2]], name)
+      }
+    }
+  }
+
+  used := make(map[string]bool)
+  if len(aliases) == 0 {
+    return used
+  }
+
+  counts := make(map[string]int)
+  code := stripCommentsAndStrings(src)
+  for _, m := range identifierRegex.FindAllStringIndex(code, -1) {
+    if m[0] == 0 || code[m[0] - 1] != '.' {
+      counts[code[m[0]:m[1]]]++
+    }
+  }
+
+  for ns, names := range aliases {
+    for _, name := range names {
+      if counts[name] > 1 {
+        used[ns] = true
+      }
+    }
+  }
+  return used
+}
+
+// Returns the namespaces referenced in a file with the line of their first
+// reference. A dotted name references the longest of the known namespaces, or
+// the namespaces required by the file, that is its prefix (e.g.,
+// "goog.dom.classlist.add" references "goog.dom.classlist").
+func referencedNamespaces(src string, known map[string]bool,
+                          requires []closureRequire) map[string]int {
+  required := make(map[string]bool)
+  for _, req := range requires {
+    required[req.Namespace] = true
+  }
+
+  code := []byte(stripCommentsAndStrings(src))
+  // Requires and provides are left as "goog.require()" after stripping. They
+  // are blanked in place, keeping the line breaks.
+  for _, m := range requireStatementRegex.FindAllIndex(code, -1) {
+    for i := m[0]; i < m[1]; i++ {
+      if code[i] != '\n' {
+        code[i] = ' '
+      }
+    }
+  }
+
+  refs := make(map[string]int)
+  // Line of the current match, counted on from the previous match.
+  line, lineStart := 1, 0
+  for _, m := range dottedNameRegex.FindAllIndex(code, -1) {
+    line += bytes.Count(code[lineStart:m[0]], []byte("\n"))
+    lineStart = m[0]
+    if m[0] > 0 && code[m[0] - 1] == '.' {
+      continue
+    }
+
+    name := string(code[m[0]:m[1]])
+    for {
+      if known[name] || required[name] {
+        if _, ok := refs[name]; !ok {
+          refs[name] = line
+        }
+        break
+      }
+
+      dot := strings.LastIndex(name, ".")
+      if dot < 0 {
+        break
+      }
+      name = name[:dot]
+    }
+  }
+  return refs
+}
+
+// Lints a file against the dependency graph. namespaces are the namespaces of
+// the graph.
+func lintFile(name string, src string, scan scanResult,
+              g depgraph.DependencyGraph,
+              namespaces map[string]bool) []Diagnostic {
+  diags := []Diagnostic{}
+  required := make(map[string]bool)
+  for _, req := range scan.Requires {
+    required[req.Namespace] = true
+  }
+
+  provided := make(map[string]bool)
+  for _, pkg := range scan.Provides {
+    provided[pkg] = true
+  }
+
+  refs := referencedNamespaces(src, namespaces, scan.Requires)
+  aliased := usedRequireAliases(src)
+  // Last require of every kind, to check their order.
+  last := make(map[depgraph.EdgeKind]string)
+  for _, req := range scan.Requires {
+    // Forward declared namespaces may be provided elsewhere (e.g., externs).
+    _, ok := g.Nodes[req.Namespace]
+    if !ok && req.Kind != depgraph.ForwardDeclareEdge {
+      diags = append(diags, Diagnostic{
+        Severity: SeverityError,
+        File: name,
+        Line: req.Line,
+        Message: "Required namespace " + req.Namespace + " is not provided.",
+        Check: CheckMissingProvide,
+      })
+    }
+
+    // Type-only namespaces are used in annotations, which are not checked.
+    _, ok = refs[req.Namespace]
+    if !ok && !aliased[req.Namespace] && req.Kind == depgraph.StrongEdge {
+      diags = append(diags, Diagnostic{
+        Severity: SeverityWarning,
+        File: name,
+        Line: req.Line,
+        Message: "Required namespace " + req.Namespace + " is not used.",
+        Check: CheckUnusedRequire,
+      })
+    }
+
+    prev, ok := last[req.Kind]
+    if ok && req.Namespace < prev {
+      diags = append(diags, Diagnostic{
+        Severity: SeverityWarning,
+        File: name,
+        Line: req.Line,
+        Message: fmt.Sprintf("Requires are not sorted: %s should be before " +
+                             "%s.", req.Namespace, prev),
+        Check: CheckUnsortedRequires,
+      })
+    }
+    last[req.Kind] = req.Namespace
+  }
+
+  missing := []string{}
+  for ns := range refs {
+    if !required[ns] && !provided[ns] {
+      missing = append(missing, ns)
+    }
+  }
+  sort.Strings(missing)
+
+  for _, ns := range missing {
+    diags = append(diags, Diagnostic{
+      Severity: SeverityWarning,
+      File: name,
+      Line: refs[ns],
+      Message: "Namespace " + ns + " is used but not required.",
+      Check: CheckMissingRequire,
+    })
+  }
+
+  sort.SliceStable(diags, func(i, j int) bool {
+    return diags[i].Line < diags[j].Line
+  })
+  return diags
+}
+
+// Returns the JavaScript files of Compiler.Sources, which are the files
+// linted by default. Ignored files are skipped.
+func (cc *Compiler) lintableSources() []string {
+  names := []string{}
+  cc.walkRoot(cc.sourceRoots()[0], func(root SourceRoot, name string) {
+    if cc.isSourceJavascript(name) {
+      names = append(names, name)
+    }
+  })
+  return names
+}
+
+// Lints the requires of the given source files, or of all JavaScript files in
+// Compiler.Sources if no file is given. Checks for requires of namespaces that
+// no file provides, unused requires, namespaces used without a require, and
+// unsorted requires. Forward declared namespaces need no provider, type-only
+// requires are not checked for use, and every kind of require is sorted on
+// its own. Requires bound to names, as in goog.modules, are used if any of
+// their names is.
+func (cc *Compiler) Lint(names ...string) ([]Diagnostic, error) {
+  if len(names) == 0 {
+    names = cc.lintableSources()
+  }
+
+  g := *cc.sharedGraph()
+  namespaces := graphNamespaces(g)
+  diags := []Diagnostic{}
+  for _, name := range names {
+    name = cleanName(name)
+    content, err := fs.ReadFile(cc.sourceTree(), name)
+    if err != nil {
+      return nil, err
+    }
+
+    src := string(content)
+    diags = append(diags, lintFile(name, src, scanClosureSource(src), g,
+                                   namespaces)...)
+  }
+  return diags, nil
+}
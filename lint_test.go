@@ -0,0 +1,206 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "testing"
+  "testing/fstest"
+)
+
+func TestStripCommentsAndStrings(t *testing.T) {
+  src := "a('x.y'); // goog.dom\n/* goog.array\n */ b(\"q\\\"r\");"
+  expected := "a(     );            \n             \n    b(      );"
+  if stripped := stripCommentsAndStrings(src); stripped != expected {
+    t.Errorf("Invalid stripped source: %q", stripped)
+  }
+}
+
+func newLintCompiler() *Compiler {
+  return NewCompilerWithSources(fstest.MapFS{
+    "app.js": {Data: []byte(`goog.provide('app');
+
+goog.require('goog.dom');
+goog.require('goog.array');
+goog.require('app.missing');
+goog.require('goog.string');
+
+// goog.events is mentioned in a comment only.
+app.main = function() {
+  goog.dom.classlist.add(goog.dom.getElement('x'), 'y');
+  goog.array.forEach([], app.util.noop);
+  var s = 'goog.events';
+};
+`)},
+    "lib/dom.js": {Data: []byte("goog.provide('goog.dom');")},
+    "lib/classlist.js": {Data: []byte("goog.provide('goog.dom.classlist');")},
+    "lib/array.js": {Data: []byte("goog.provide('goog.array');")},
+    "lib/string.js": {Data: []byte("goog.provide('goog.string');")},
+    "lib/events.js": {Data: []byte("goog.provide('goog.events');")},
+    "util.js": {Data: []byte("goog.provide('app.util');")},
+  })
+}
+
+func TestLint(t *testing.T) {
+  cc := newLintCompiler()
+  diags, err := cc.Lint("app.js")
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  expected := []struct {
+    Check string
+    Line int
+    Message string
+  }{
+    {CheckUnsortedRequires, 4,
+     "Requires are not sorted: goog.array should be before goog.dom."},
+    {CheckMissingProvide, 5, "Required namespace app.missing is not provided."},
+    {CheckUnusedRequire, 5, "Required namespace app.missing is not used."},
+    {CheckUnsortedRequires, 5,
+     "Requires are not sorted: app.missing should be before goog.array."},
+    {CheckUnusedRequire, 6, "Required namespace goog.string is not used."},
+    {CheckMissingRequire, 10,
+     "Namespace goog.dom.classlist is used but not required."},
+    {CheckMissingRequire, 11, "Namespace app.util is used but not required."},
+  }
+
+  if len(diags) != len(expected) {
+    t.Fatal("Invalid diagnostics: ", diags)
+  }
+
+  for i, d := range diags {
+    e := expected[i]
+    if d.Check != e.Check || d.Line != e.Line || d.Message != e.Message ||
+       d.File != "app.js" {
+      t.Error("Invalid diagnostic: ", d, e)
+    }
+  }
+
+  if diags[1].Severity != SeverityError ||
+     diags[2].Severity != SeverityWarning {
+    t.Error("Invalid severities: ", diags[1], diags[2])
+  }
+}
+
+func TestLintAllSources(t *testing.T) {
+  cc := NewCompiler("test_resources")
+  diags, err := cc.Lint()
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // The test packages require each other without referencing.
+  unused := 0
+  for _, d := range diags {
+    if d.Check == CheckUnusedRequire {
+      unused++
+    }
+  }
+
+  if unused != 3 {
+    t.Error("Invalid diagnostics: ", diags)
+  }
+}
+
+func TestReferencedNamespaces(t *testing.T) {
+  src := "goog.provide('app');\ngoog.require(\n    'goog.dom');\n" +
+         "// goog.array in a comment.\nvar el = goog.dom.getElement('x');\n" +
+         "goog.array.forEach([], f);\ngoog.dom.classlist.add(el, 'y');\n"
+  known := map[string]bool{"goog.array": true, "goog.dom.classlist": true}
+  requires := []closureRequire{{Namespace: "goog.dom"}}
+
+  refs := referencedNamespaces(src, known, requires)
+  if len(refs) != 3 || refs["goog.dom"] != 5 || refs["goog.array"] != 6 ||
+     refs["goog.dom.classlist"] != 7 {
+    t.Error("Invalid references: ", refs)
+  }
+}
+
+func TestLintRequireAliases(t *testing.T) {
+  cc := NewCompilerWithSources(fstest.MapFS{
+    "m.js": {Data: []byte(`goog.module('m');
+const a = goog.require('x.a');
+const {f, g: h} = goog.require('x.b');
+const unused = goog.require('x.c');
+exports.g = a.f;
+exports.h = h;
+`)},
+    "x.js": {Data: []byte(
+        "goog.provide('x.a');\ngoog.provide('x.b');\ngoog.provide('x.c');")},
+  })
+
+  diags, err := cc.Lint("m.js")
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  if len(diags) != 1 || diags[0].Check != CheckUnusedRequire ||
+     diags[0].Line != 4 {
+    t.Error("Invalid diagnostics: ", diags)
+  }
+}
+
+func TestUsedRequireAliases(t *testing.T) {
+  src := "const a = goog.require('a');\n" +
+         "let {b, c: d} = goog.require('b');\n" +
+         "var e = goog.require('e');\n" +
+         "a(); d(); x.e; // e\n"
+  used := usedRequireAliases(src)
+  if len(used) != 2 || !used["a"] || !used["b"] {
+    t.Error("Invalid used aliases: ", used)
+  }
+}
+
+func TestLintRequireKinds(t *testing.T) {
+  cc := NewCompilerWithSources(fstest.MapFS{
+    "app.js": {Data: []byte(`goog.provide('app');
+
+goog.require('goog.dom');
+goog.requireType('goog.events.Event');
+goog.requireType('app.Missing');
+goog.forwardDeclare('goog.debug');
+goog.forwardDeclare('extern.Type');
+
+/** @param {goog.events.Event} e */
+app.main = function(e) {
+  goog.dom.getElement('x');
+};
+`)},
+    "lib/dom.js": {Data: []byte("goog.provide('goog.dom');")},
+    "lib/event.js": {Data: []byte("goog.provide('goog.events.Event');")},
+    "lib/debug.js": {Data: []byte("goog.provide('goog.debug');")},
+  })
+
+  diags, err := cc.Lint("app.js")
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // Type-only requires need a provider, but are not checked for use, and
+  // forward declarations need neither. Each kind is sorted on its own.
+  if len(diags) != 3 || diags[0].Check != CheckMissingProvide ||
+     diags[0].Line != 5 || diags[1].Check != CheckUnsortedRequires ||
+     diags[1].Line != 5 || diags[2].Check != CheckUnsortedRequires ||
+     diags[2].Line != 7 {
+    t.Error("Invalid diagnostics: ", diags)
+  }
+
+  // Only goog.require pulls a namespace into the bundle.
+  g := cc.DependencyGraph()
+  deps := g.GetDependenciesOfPackage("app")
+  if len(deps) != 2 || deps[0].Pkg != "goog.dom" {
+    t.Error("Invalid dependencies: ", deps)
+  }
+}
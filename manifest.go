@@ -0,0 +1,342 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "crypto/sha256"
+  "encoding/hex"
+  "encoding/json"
+  "io/fs"
+  "strings"
+  "sync"
+  "time"
+
+  "github.com/golang/glog"
+)
+
+// Content hash of a compiled output. The hash is recomputed only when the
+// modification time or the size of the output changes.
+type fingerprintEntry struct {
+  Hash string
+  ModTime time.Time
+  Size int64
+}
+
+type manifest struct {
+  entries map[string]fingerprintEntry
+  // Hashes of sources at their last compilation keyed by the output path and
+  // the source path.
+  sources map[string]string
+  // Modification times of sources found unchanged since their last
+  // compilation, with the same keys as sources.
+  revalidated map[string]time.Time
+  // Whether entries changed since the manifest was last written.
+  changed bool
+  mutex sync.Mutex
+}
+
+func newManifest() *manifest {
+  return &manifest{
+    entries: make(map[string]fingerprintEntry),
+    sources: make(map[string]string),
+    revalidated: make(map[string]time.Time),
+  }
+}
+
+func (cc *Compiler) sourceHash(srcName string) (string, error) {
+  content, err := fs.ReadFile(cc.sourceTree(), srcName)
+  if err != nil {
+    return "", err
+  }
+
+  sum := sha256.Sum256(content)
+  return hex.EncodeToString(sum[:]), nil
+}
+
+// Returns the content hashes of the existing sources in srcNames.
+func (cc *Compiler) hashSources(srcNames ...string) map[string]string {
+  hashes := make(map[string]string)
+  for _, srcName := range srcNames {
+    hash, err := cc.sourceHash(srcName)
+    if err == nil {
+      hashes[srcName] = hash
+    }
+  }
+  return hashes
+}
+
+// Records the hashes of the sources used to compile an output.
+func (cc *Compiler) recordSources(outName string, hashes map[string]string) {
+  cc.manifest.mutex.Lock()
+  defer cc.manifest.mutex.Unlock()
+  for srcName, hash := range hashes {
+    cc.manifest.sources[outName + "\x00" + srcName] = hash
+    delete(cc.manifest.revalidated, outName + "\x00" + srcName)
+  }
+}
+
+// Whether the output exists in Compiler.Outputs and is not older than the
+// source. A source that is touched but not changed since the last compilation
+// of the output does not make it stale (see Compiler.revalidateSource).
+func (cc *Compiler) isUpToDate(srcName string, outName string) bool {
+  srcModTime, err := cc.sourceModTime(srcName)
+  if err != nil {
+    return false
+  }
+
+  outStat, err := cc.Outputs.Stat(outName)
+  if err != nil {
+    return false
+  }
+
+  if !outStat.ModTime().Before(srcModTime) {
+    return true
+  }
+
+  key := outName + "\x00" + srcName
+  cc.manifest.mutex.Lock()
+  recorded, ok := cc.manifest.sources[key]
+  revalidated, isRevalidated := cc.manifest.revalidated[key]
+  cc.manifest.mutex.Unlock()
+  if !ok {
+    return false
+  }
+
+  if isRevalidated && revalidated.Equal(srcModTime) {
+    return true
+  }
+
+  current, err := cc.sourceHash(srcName)
+  return err == nil && current == recorded
+}
+
+// Records the modification time of a source that is touched but not changed
+// since the output was compiled, so later checks do not hash the source again.
+// The output itself is not rewritten.
+func (cc *Compiler) revalidateSource(srcName string, outName string) {
+  srcModTime, err := cc.sourceModTime(srcName)
+  if err != nil {
+    return
+  }
+
+  outStat, err := cc.Outputs.Stat(outName)
+  if err != nil || !outStat.ModTime().Before(srcModTime) ||
+     !cc.isUpToDate(srcName, outName) {
+    return
+  }
+
+  glog.V(1).Info(srcName, " is touched but not changed since compiling ",
+                 outName)
+  cc.manifest.mutex.Lock()
+  cc.manifest.revalidated[outName + "\x00" + srcName] = srcModTime
+  cc.manifest.mutex.Unlock()
+}
+
+func isHex(s string) bool {
+  for _, c := range s {
+    if !strings.ContainsRune("0123456789abcdef", c) {
+      return false
+    }
+  }
+  return true
+}
+
+// Splits a fingerprinted path (e.g., "app.3f9a1c2b.min.js") into the logical
+// path ("app.min.js") and the hash ("3f9a1c2b"). Other paths are returned as
+// is with an empty hash.
+func (cc *Compiler) parseFingerprintedPath(relPath string) (string, string) {
+  for _, suffix := range []string{cc.CompiledSuffix, cc.CompiledCssSuffix} {
+    if !strings.HasSuffix(relPath, suffix) {
+      continue
+    }
+
+    base := relPath[:len(relPath) - len(suffix)]
+    dot := strings.LastIndex(base, ".")
+    if dot < 0 || strings.Contains(base[dot:], "/") {
+      return relPath, ""
+    }
+
+    hash := base[dot + 1:]
+    if len(hash) != cc.fingerprintLength() || !isHex(hash) {
+      return relPath, ""
+    }
+    return base[:dot] + suffix, hash
+  }
+  return relPath, ""
+}
+
+// Returns the fingerprinted name of a compiled output.
+func (cc *Compiler) getFingerprintedPath(relPath string, hash string) string {
+  for _, suffix := range []string{cc.CompiledSuffix, cc.CompiledCssSuffix} {
+    if strings.HasSuffix(relPath, suffix) {
+      return relPath[:len(relPath) - len(suffix)] + "." + hash + suffix
+    }
+  }
+  return relPath
+}
+
+// Returns Compiler.FingerprintLength, or the default if it is not positive,
+// capped to the length of a SHA-256 in hex.
+func (cc *Compiler) fingerprintLength() int {
+  switch {
+  case cc.FingerprintLength <= 0:
+    return DefaultFingerprintLength
+  case cc.FingerprintLength > sha256.Size * 2:
+    return sha256.Size * 2
+  }
+  return cc.FingerprintLength
+}
+
+// Returns the short content hash of a compiled output, and records it in the
+// manifest.
+func (cc *Compiler) fingerprint(relPath string) (string, error) {
+  hash, err := cc.contentHash(relPath)
+  if err != nil {
+    return "", err
+  }
+  return hash[:cc.fingerprintLength()], nil
+}
+
+// Returns the SHA-256 of a compiled output in hex. The output is read only if
+// it is changed since the last call. The manifest is not written (see
+// Compiler.updateManifest).
+func (cc *Compiler) contentHash(relPath string) (string, error) {
+  key := cleanName(relPath)
+  stat, err := cc.Outputs.Stat(key)
+  if err != nil {
+    return "", err
+  }
+
+  cc.manifest.mutex.Lock()
+  entry, ok := cc.manifest.entries[key]
+  cc.manifest.mutex.Unlock()
+  if ok && entry.ModTime.Equal(stat.ModTime()) && entry.Size == stat.Size() {
+    return entry.Hash, nil
+  }
+
+  content, err := cc.Outputs.ReadFile(key)
+  if err != nil {
+    return "", err
+  }
+
+  sum := sha256.Sum256(content)
+  hash := hex.EncodeToString(sum[:])
+
+  cc.manifest.mutex.Lock()
+  if entry, ok := cc.manifest.entries[key]; !ok || entry.Hash != hash {
+    cc.manifest.changed = true
+  }
+  cc.manifest.entries[key] = fingerprintEntry{
+    Hash: hash,
+    ModTime: stat.ModTime(),
+    Size: stat.Size(),
+  }
+  cc.manifest.mutex.Unlock()
+  return hash, nil
+}
+
+// Records the fingerprint of a compiled output, and writes the manifest if any
+// fingerprint changed since it was last written.
+func (cc *Compiler) updateManifest(relPath string) {
+  if _, err := cc.contentHash(relPath); err != nil {
+    glog.Warning("Cannot fingerprint ", relPath, ": ", err)
+    return
+  }
+
+  cc.manifest.mutex.Lock()
+  changed := cc.manifest.changed
+  cc.manifest.mutex.Unlock()
+  if changed {
+    cc.writeManifest()
+  }
+}
+
+// Returns the fingerprinted path of a compiled output (e.g., "/app.min.js" is
+// mapped to "/app.3f9a1c2b.min.js"). The output is compiled if needed. If the
+// output is not available, relPath is returned as is.
+func (cc *Compiler) AssetPath(relPath string) string {
+  if cc.CompileOnDemand {
+    var err error
+    switch {
+    case cc.isCompiledJavascript(relPath) && !cc.jsIsAlreadyCompiled(relPath):
+      err = cc.Compile(relPath)
+    case cc.isCompiledJavascript(relPath):
+      cc.revalidateSource(cc.getSourceJavascriptName(relPath),
+                          cc.getCompiledJavascriptName(relPath))
+    case cc.isCompiledCss(relPath) && !cc.cssIsAlreadyCompiled(relPath):
+      err = cc.CompileCss(relPath)
+    case cc.isCompiledCss(relPath):
+      cc.revalidateSource(cc.getSourceCssName(relPath),
+                          cc.getCompiledCssName(relPath))
+    }
+
+    if err != nil {
+      glog.Error("Cannot compile ", relPath, ": ", err)
+      return relPath
+    }
+  }
+
+  hash, err := cc.fingerprint(relPath)
+  if err != nil {
+    return relPath
+  }
+
+  cc.updateManifest(relPath)
+  return cc.getFingerprintedPath(relPath, hash)
+}
+
+// Writes the manifest of fingerprinted outputs into Compiler.Outputs. Entries
+// of the existing manifest, written by other compilers or processes on the
+// same outputs, are kept as long as their outputs exist.
+func (cc *Compiler) writeManifest() {
+  if cc.ManifestName == "" {
+    return
+  }
+
+  cc.manifest.mutex.Lock()
+  defer cc.manifest.mutex.Unlock()
+
+  names := make(map[string]string)
+  if content, err := cc.Outputs.ReadFile(cc.ManifestName); err == nil {
+    existing := make(map[string]string)
+    if err := json.Unmarshal(content, &existing); err != nil {
+      glog.Warning("Ignoring the invalid manifest ", cc.ManifestName, ": ",
+                   err)
+    }
+
+    for key, name := range existing {
+      if _, err := cc.Outputs.Stat(key); err == nil {
+        names[key] = name
+      }
+    }
+  }
+
+  for key, entry := range cc.manifest.entries {
+    names[key] = cc.getFingerprintedPath(key,
+                                         entry.Hash[:cc.fingerprintLength()])
+  }
+  cc.manifest.changed = false
+
+  content, err := json.MarshalIndent(names, "", "  ")
+  if err != nil {
+    glog.Error("Cannot encode the manifest: ", err)
+    return
+  }
+
+  err = cc.Outputs.WriteFile(cc.ManifestName, content)
+  if err != nil {
+    glog.Error("Cannot write the manifest to ", cc.ManifestName, ": ", err)
+  }
+}
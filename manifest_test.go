@@ -0,0 +1,182 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "encoding/json"
+  "io/ioutil"
+  "net/http"
+  "net/http/httptest"
+  "os"
+  "path/filepath"
+  "testing"
+  "time"
+)
+
+// Creates a root with an already compiled "app.min.js".
+func newCompiledRoot(t *testing.T) string {
+  root := t.TempDir()
+  files := [...]struct {
+    Name string
+    Content string
+  }{
+    {"app.js", "goog.provide('app');\n"},
+    {"app.min.js", "var app={};\n"},
+  }
+
+  // Outputs should be newer than sources.
+  modTime := time.Now().Add(-time.Hour)
+  for _, f := range files {
+    path := filepath.Join(root, f.Name)
+    err := ioutil.WriteFile(path, []byte(f.Content), 0644)
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    modTime = modTime.Add(time.Minute)
+    os.Chtimes(path, modTime, modTime)
+  }
+  return root
+}
+
+func TestParseFingerprintedPath(t *testing.T) {
+  cc := NewCompiler(".")
+  paths := [...]struct {
+    Path string
+    Logical string
+    Hash string
+  }{
+    {"/app.3f9a1c2b.min.js", "/app.min.js", "3f9a1c2b"},
+    {"/css/app.3f9a1c2b.min.css", "/css/app.min.css", "3f9a1c2b"},
+    {"/app.min.js", "/app.min.js", ""},
+    {"/jquery.1.min.js", "/jquery.1.min.js", ""},
+    {"/app.3f9a1c2x.min.js", "/app.3f9a1c2x.min.js", ""},
+    {"/v1.3f9a1c2b/app.min.js", "/v1.3f9a1c2b/app.min.js", ""},
+  }
+
+  for _, p := range paths {
+    logical, hash := cc.parseFingerprintedPath(p.Path)
+    if logical != p.Logical || hash != p.Hash {
+      t.Error("Invalid fingerprinted path: ", p.Path, logical, hash)
+    }
+
+    if hash != "" && cc.getFingerprintedPath(logical, hash) != p.Path {
+      t.Error("Cannot reconstruct fingerprinted path: ", p.Path)
+    }
+  }
+}
+
+func TestAssetPathAndManifest(t *testing.T) {
+  cc := NewCompiler(newCompiledRoot(t))
+  assetPath := cc.AssetPath("/app.min.js")
+  logical, hash := cc.parseFingerprintedPath(assetPath)
+  if logical != "/app.min.js" || hash == "" {
+    t.Fatal("Invalid asset path: ", assetPath)
+  }
+
+  content, err := cc.Outputs.ReadFile(cc.ManifestName)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  names := map[string]string{}
+  json.Unmarshal(content, &names)
+  if "/" + names["app.min.js"] != assetPath {
+    t.Error("Invalid manifest: ", string(content))
+  }
+}
+
+func TestManifestKeepsOtherEntries(t *testing.T) {
+  root := newCompiledRoot(t)
+  err := ioutil.WriteFile(filepath.Join(root, "b.min.js"), []byte("var b;"),
+                          0644)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // Two compilers, e.g., a server and a build, on the same outputs.
+  first := NewCompiler(root)
+  first.CompileOnDemand = false
+  first.AssetPath("app.min.js")
+
+  second := NewCompiler(root)
+  second.CompileOnDemand = false
+  second.AssetPath("b.min.js")
+
+  content, err := second.Outputs.ReadFile(second.ManifestName)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  names := map[string]string{}
+  json.Unmarshal(content, &names)
+  if len(names) != 2 || names["app.min.js"] == "" || names["b.min.js"] == "" {
+    t.Error("Invalid manifest: ", string(content))
+  }
+}
+
+func TestServeFingerprinted(t *testing.T) {
+  cc := NewCompiler(newCompiledRoot(t))
+  assetPath := cc.AssetPath("/app.min.js")
+
+  res := httptest.NewRecorder()
+  req, _ := http.NewRequest("GET", assetPath, nil)
+  ServeHttp(res, req, &cc)
+  if res.Code != http.StatusOK || res.Body.String() != "var app={};\n" {
+    t.Error("Cannot serve fingerprinted output: ", res.Code)
+  }
+
+  if res.Header().Get("Cache-Control") == "" {
+    t.Error("No cache control for fingerprinted output.")
+  }
+
+  res = httptest.NewRecorder()
+  req, _ = http.NewRequest("GET", "/app.00000000.min.js", nil)
+  ServeHttp(res, req, &cc)
+  if res.Code != http.StatusNotFound {
+    t.Error("Served an output with a stale fingerprint: ", res.Code)
+  }
+}
+
+func TestFingerprintLength(t *testing.T) {
+  cc := NewCompiler(newCompiledRoot(t))
+  lengths := map[int]int{-1: DefaultFingerprintLength, 0: 8, 12: 12, 100: 64}
+  for length, expected := range lengths {
+    cc.FingerprintLength = length
+    _, hash := cc.parseFingerprintedPath(cc.AssetPath("/app.min.js"))
+    if len(hash) != expected {
+      t.Error("Invalid fingerprint of length ", length, ": ", hash)
+    }
+  }
+}
+
+func TestServeDoesNotWriteManifest(t *testing.T) {
+  cc := NewCompiler(newCompiledRoot(t))
+  res := httptest.NewRecorder()
+  req, _ := http.NewRequest("GET", "/app.min.js", nil)
+  ServeHttp(res, req, &cc)
+  if res.Code != http.StatusOK {
+    t.Fatal("Cannot serve the output: ", res.Code)
+  }
+
+  if _, err := cc.Outputs.Stat(cc.ManifestName); err == nil {
+    t.Error("Manifest is written when serving an output.")
+  }
+
+  cc.AssetPath("/app.min.js")
+  if _, err := cc.Outputs.Stat(cc.ManifestName); err != nil {
+    t.Error("Manifest is not written for an asset path: ", err)
+  }
+}
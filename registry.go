@@ -0,0 +1,173 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "errors"
+  "fmt"
+  "net/http"
+  "net/url"
+  "strings"
+  "sync"
+)
+
+// Registry is an http.Handler serving several compiler configurations under
+// URL prefixes (e.g., a public site under "/js/" and an admin app under
+// "/admin/js/"). Configurations share the scanning of their source roots, but
+// each has its own dependency graph, outputs and fingerprints.
+type Registry struct {
+  entries []*registryEntry
+  scans *scanCache
+  mutex sync.RWMutex
+}
+
+type registryEntry struct {
+  name string
+  prefix string
+  cc *Compiler
+}
+
+// Creates an empty registry.
+func NewRegistry() *Registry {
+  return &Registry{scans: newScanCache()}
+}
+
+func normalizePrefix(prefix string) string {
+  prefix = cleanName(prefix)
+  if prefix == "" {
+    return "/"
+  }
+  return "/" + prefix + "/"
+}
+
+// Adds a named configuration served under the URL prefix. Requests are passed
+// to the configuration with the prefix stripped. Outputs of the configuration
+// are kept under a directory with its name in Compiler.Outputs, so
+// configurations with the same sources do not overwrite each other's outputs.
+// The registry takes over cc, which should not be used by other handlers.
+func (r *Registry) Add(name string, prefix string, cc *Compiler) error {
+  if name == "" || strings.Contains(name, "/") {
+    return errors.New(fmt.Sprintf("Invalid configuration name: %q.", name))
+  }
+
+  prefix = normalizePrefix(prefix)
+
+  r.mutex.Lock()
+  defer r.mutex.Unlock()
+
+  for _, e := range r.entries {
+    if e.name == name {
+      return errors.New(fmt.Sprintf("Configuration %s is already added.",
+                                    name))
+    }
+
+    if e.prefix == prefix {
+      return errors.New(fmt.Sprintf("Prefix %s is already used by %s.",
+                                    prefix, e.name))
+    }
+  }
+
+  // Compilers copied from one another share their graph, manifest and
+  // statistics, so each configuration gets fresh ones. The outputs of all
+  // configurations are skipped when the sources are walked.
+  cc.Outputs = prefixedStore{cc.Outputs, name}
+  cc.manifest = newManifest()
+  cc.stats = newCompileStats()
+  cc.graph = newGraphSnapshot()
+  cc.scans = r.scans
+  cc.registry = r
+
+  r.entries = append(r.entries, &registryEntry{name, prefix, cc})
+  return nil
+}
+
+// Returns the compilers of all configurations.
+func (r *Registry) compilers() []*Compiler {
+  r.mutex.RLock()
+  defer r.mutex.RUnlock()
+
+  compilers := make([]*Compiler, 0, len(r.entries))
+  for _, e := range r.entries {
+    compilers = append(compilers, e.cc)
+  }
+  return compilers
+}
+
+// Returns the named configuration, or nil if there is none.
+func (r *Registry) Compiler(name string) *Compiler {
+  r.mutex.RLock()
+  defer r.mutex.RUnlock()
+
+  for _, e := range r.entries {
+    if e.name == name {
+      return e.cc
+    }
+  }
+  return nil
+}
+
+// Returns the fingerprinted URL of an output of the named configuration,
+// including the prefix of the configuration (e.g., "/admin/js/app.min.js" is
+// returned for "app.min.js").
+func (r *Registry) AssetPath(name string, relPath string) string {
+  r.mutex.RLock()
+  defer r.mutex.RUnlock()
+
+  for _, e := range r.entries {
+    if e.name == name {
+      return e.prefix + cleanName(e.cc.AssetPath(relPath))
+    }
+  }
+  return relPath
+}
+
+// Returns the configuration with the longest prefix matching the path.
+func (r *Registry) match(urlPath string) *registryEntry {
+  r.mutex.RLock()
+  defer r.mutex.RUnlock()
+
+  var match *registryEntry
+  for _, e := range r.entries {
+    if !strings.HasPrefix(urlPath, e.prefix) {
+      continue
+    }
+
+    if match == nil || len(e.prefix) > len(match.prefix) {
+      match = e
+    }
+  }
+  return match
+}
+
+func (r *Registry) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+  e := r.match(req.URL.Path)
+  if e == nil {
+    http.NotFound(res, req)
+    return
+  }
+
+  stripped := new(http.Request)
+  *stripped = *req
+  stripped.URL = new(url.URL)
+  *stripped.URL = *req.URL
+  stripped.URL.Path = "/" + strings.TrimPrefix(req.URL.Path, e.prefix)
+
+  path, _ := e.cc.parseFingerprintedPath(stripped.URL.Path)
+  if e.cc.isCompiledCss(path) {
+    ServeCssHttp(res, stripped, e.cc)
+    return
+  }
+  ServeHttp(res, stripped, e.cc)
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "io/ioutil"
+  "net/http"
+  "net/http/httptest"
+  "path/filepath"
+  "strings"
+  "testing"
+)
+
+func newTestRegistry(t *testing.T) *Registry {
+  root := t.TempDir()
+  for _, name := range []string{"pkg1.js", "pkg2.js", "pkg3.js"} {
+    content, _ := ioutil.ReadFile(filepath.Join("test_resources", name))
+    ioutil.WriteFile(filepath.Join(root, name), content, 0644)
+  }
+
+  public := NewCompiler(root)
+  public.CompileOnDemand = false
+  admin := NewCompiler(root)
+  admin.CompileOnDemand = false
+  admin.CompilationLevel = AdvancedOptimizations
+
+  r := NewRegistry()
+  if err := r.Add("public", "/js/", &public); err != nil {
+    t.Fatal(err)
+  }
+  if err := r.Add("admin", "admin/js", &admin); err != nil {
+    t.Fatal(err)
+  }
+  return r
+}
+
+func TestRegistryAdd(t *testing.T) {
+  r := newTestRegistry(t)
+  if r.Compiler("admin").CompilationLevel != AdvancedOptimizations ||
+     r.Compiler("public").CompilationLevel != SimpleOptimizations {
+    t.Error("Configurations are not kept separately.")
+  }
+
+  if r.Compiler("missing") != nil {
+    t.Error("Found a missing configuration.")
+  }
+
+  other := NewCompiler("")
+  if r.Add("public", "/other/", &other) == nil {
+    t.Error("Duplicate name is accepted.")
+  }
+
+  if r.Add("other", "/admin/js", &other) == nil {
+    t.Error("Duplicate prefix is accepted.")
+  }
+}
+
+func TestRegistrySkipsOutputs(t *testing.T) {
+  r := newTestRegistry(t)
+  // Outputs of both configurations are in the source directory.
+  for _, name := range []string{"public", "admin"} {
+    err := r.Compiler(name).Outputs.WriteFile("pkg1.soy.js",
+                                              []byte("goog.provide('pkg1');"))
+    if err != nil {
+      t.Fatal(err)
+    }
+  }
+
+  for _, name := range []string{"public", "admin"} {
+    g := r.Compiler(name).reloadDependencyGraph()
+    if len(g.Conflicts()) != 0 || g.Nodes["pkg1"].Path != "pkg1.js" {
+      t.Error("Outputs are scanned as sources: ", g.Conflicts())
+    }
+  }
+}
+
+func TestRegistrySharesScans(t *testing.T) {
+  r := newTestRegistry(t)
+  r.Compiler("public").reloadDependencyGraph()
+  r.Compiler("admin").reloadDependencyGraph()
+
+  if r.scans.misses != 3 || r.scans.hits != 3 {
+    t.Error("Sources are not scanned once: ", r.scans.misses, r.scans.hits)
+  }
+
+  g := r.Compiler("admin").DependencyGraph()
+  if len(g.Nodes) != 3 {
+    t.Error("Invalid dependency graph: ", g.Nodes)
+  }
+}
+
+func TestRegistryServeHttp(t *testing.T) {
+  r := newTestRegistry(t)
+  r.Compiler("admin").Outputs.WriteFile("pkg1.min.js", []byte("var admin;"))
+
+  req := httptest.NewRequest("GET", "/admin/js/pkg1.min.js", nil)
+  res := httptest.NewRecorder()
+  r.ServeHTTP(res, req)
+  if res.Code != http.StatusOK || res.Body.String() != "var admin;" {
+    t.Error("Output is not served: ", res.Code, res.Body.String())
+  }
+
+  // The output of admin is not visible to the public configuration.
+  req = httptest.NewRequest("GET", "/js/pkg1.min.js", nil)
+  res = httptest.NewRecorder()
+  r.ServeHTTP(res, req)
+  if res.Code != http.StatusNotFound {
+    t.Error("Outputs are shared among configurations: ", res.Code)
+  }
+
+  req = httptest.NewRequest("GET", "/other/pkg1.min.js", nil)
+  res = httptest.NewRecorder()
+  r.ServeHTTP(res, req)
+  if res.Code != http.StatusNotFound {
+    t.Error("Unknown prefix is served: ", res.Code)
+  }
+
+  path := r.AssetPath("admin", "pkg1.min.js")
+  if !strings.HasPrefix(path, "/admin/js/pkg1.") {
+    t.Error("Invalid asset path: ", path)
+  }
+}
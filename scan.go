@@ -0,0 +1,260 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "crypto/sha256"
+  "encoding/hex"
+  "fmt"
+  "io/fs"
+  "path/filepath"
+  "reflect"
+  "regexp"
+  "strings"
+  "sync"
+  "time"
+
+  "github.com/soheilhy/glosure/depgraph"
+)
+
+// Kind of module of a source file.
+type moduleKind string
+
+const (
+  // A file without provides or imports.
+  scriptModule moduleKind = "script"
+  // A file with goog.provide calls.
+  provideModule moduleKind = "provide"
+  googModule moduleKind = "goog.module"
+  es6Module moduleKind = "es6"
+)
+
+// Closure namespaces provided and required by a source file.
+type scanResult struct {
+  Provides []string `json:"provides"`
+  Requires []closureRequire `json:"requires"`
+  Module moduleKind `json:"module"`
+}
+
+type closureRequire struct {
+  Namespace string `json:"namespace"`
+  // 1-based line of the goog.require call.
+  Line int `json:"line"`
+  // Kind of the call: goog.require, goog.requireType or goog.forwardDeclare.
+  Kind depgraph.EdgeKind `json:"kind"`
+}
+
+type scanEntry struct {
+  scanResult
+  modTime time.Time
+  size int64
+  // SHA-256 of the content, in hex.
+  hash string
+}
+
+// Caches the scan results of source files by their source tree, so compilers
+// with overlapping roots scan each file once. An entry is reused as long as
+// the modification time and the size of the file are unchanged.
+type scanCache struct {
+  entries map[string]scanEntry
+  hits int64
+  misses int64
+  // Contents of the index files last read or written, keyed by their path.
+  indexes map[string][]byte
+  mutex sync.Mutex
+}
+
+func newScanCache() *scanCache {
+  return &scanCache{
+    entries: make(map[string]scanEntry),
+    indexes: make(map[string][]byte),
+  }
+}
+
+// Returns a key identifying a source tree across compilers, or an empty string
+// if the tree cannot be identified. Trees in a directory are identified by
+// their absolute path, and other trees by their address.
+func sourcesKey(fsys fs.FS) string {
+  if d, ok := fsys.(interface{ Dir() string }); ok {
+    dir, err := filepath.Abs(d.Dir())
+    if err != nil {
+      return ""
+    }
+    return "dir:" + dir
+  }
+
+  v := reflect.ValueOf(fsys)
+  switch v.Kind() {
+  case reflect.Map, reflect.Ptr:
+    return fmt.Sprintf("%T:%x", fsys, v.Pointer())
+  }
+  return ""
+}
+
+var googModuleRegex = regexp.MustCompile(`goog\.module\(\s*['"]`)
+var es6ImportExportRegex = regexp.MustCompile(`(?m)^\s*(?:import|export)\b`)
+
+// Scans the provides and requires of a file.
+func scanClosureFile(fsys fs.FS, name string) (scanResult, error) {
+  content, err := fs.ReadFile(fsys, name)
+  if err != nil {
+    return scanResult{}, err
+  }
+  return scanClosureSource(string(content)), nil
+}
+
+// Scans the provides and requires of the content of a file.
+func scanClosureSource(src string) scanResult {
+  res := scanResult{Module: scriptModule}
+  for _, m := range closureProvideRegex.FindAllStringSubmatch(src, -1) {
+    res.Provides = append(res.Provides, m[1])
+  }
+
+  switch {
+  case googModuleRegex.MatchString(src):
+    res.Module = googModule
+  case es6ImportExportRegex.MatchString(src):
+    res.Module = es6Module
+  case len(res.Provides) != 0:
+    res.Module = provideModule
+  }
+
+  line := 1
+  offset := 0
+  for _, m := range closureAnyRequireRegex.FindAllStringSubmatchIndex(src,
+                                                                       -1) {
+    line += strings.Count(src[offset:m[0]], "\n")
+    offset = m[0]
+    kind := depgraph.EdgeKind(src[m[2]:m[3]])
+    res.Requires = append(res.Requires,
+                          closureRequire{src[m[4]:m[5]], line, kind})
+  }
+  return res
+}
+
+// Scans a file of a source root using the scan cache of the compiler. name is
+// the name of the file in the root. unchanged tells whether the file is
+// unchanged since its last scan, and its scan is reused. Files with a new
+// modification time or size are read, but not scanned again if their content
+// has the same hash.
+func (cc *Compiler) scanSource(root SourceRoot, name string) (
+    res scanResult, unchanged bool, err error) {
+  key := sourcesKey(root.Sources)
+  if cc.scans == nil || key == "" {
+    res, err = scanClosureFile(root.Sources, name)
+    return
+  }
+
+  info, err := fs.Stat(root.Sources, name)
+  if err != nil {
+    return
+  }
+
+  // Files without a modification time cannot be revalidated.
+  if info.ModTime().IsZero() {
+    res, err = scanClosureFile(root.Sources, name)
+    return
+  }
+
+  key += "\x00" + name
+  cc.scans.mutex.Lock()
+  entry, ok := cc.scans.entries[key]
+  if ok && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+    cc.scans.hits++
+    cc.scans.mutex.Unlock()
+    return entry.scanResult, true, nil
+  }
+  cc.scans.mutex.Unlock()
+
+  content, err := fs.ReadFile(root.Sources, name)
+  if err != nil {
+    return
+  }
+
+  sum := sha256.Sum256(content)
+  hash := hex.EncodeToString(sum[:])
+  unchanged = ok && entry.hash == hash
+  if unchanged {
+    res = entry.scanResult
+  } else {
+    res = scanClosureSource(string(content))
+  }
+
+  cc.scans.mutex.Lock()
+  if unchanged {
+    cc.scans.hits++
+  } else {
+    cc.scans.misses++
+  }
+  cc.scans.entries[key] = scanEntry{res, info.ModTime(), info.Size(), hash}
+  cc.scans.mutex.Unlock()
+  return
+}
+
+// A file to scan in reloadDependencyGraph, and the result of its scan.
+type scanJob struct {
+  root SourceRoot
+  // Name of the file in the source tree.
+  name string
+  res scanResult
+  // Whether res is known before the scan (e.g., read from a deps file).
+  done bool
+  // Whether the file is a source file of the root, rather than a compiled
+  // template.
+  source bool
+  unchanged bool
+  err error
+}
+
+// Scans the files of the jobs that are not done, with at most workers files
+// at once. Results are stored in the jobs.
+func (cc *Compiler) scanJobs(jobs []scanJob, workers int) {
+  if workers < 1 {
+    workers = 1
+  }
+
+  indices := make(chan int)
+  var wg sync.WaitGroup
+  for i := 0; i < workers; i++ {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      for i := range indices {
+        cc.scanJob(&jobs[i])
+      }
+    }()
+  }
+
+  for i := range jobs {
+    if !jobs[i].done {
+      indices <- i
+    }
+  }
+  close(indices)
+  wg.Wait()
+}
+
+func (cc *Compiler) scanJob(job *scanJob) {
+  if cc.isSoyTemplate(job.name) {
+    // Templates compiled into Compiler.Outputs are not in the source tree.
+    job.name = cc.getGeneratedTemplateName(job.name)
+    job.res, job.err = scanClosureFile(cc.sourceTree(), job.name)
+    return
+  }
+
+  job.source = true
+  rel := job.name[len(job.root.Prefix):]
+  job.res, job.unchanged, job.err = cc.scanSource(job.root, rel)
+}
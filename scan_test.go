@@ -0,0 +1,148 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "fmt"
+  "testing"
+  "testing/fstest"
+  "time"
+
+  "github.com/soheilhy/glosure/depgraph"
+)
+
+func TestScanClosureFile(t *testing.T) {
+  res, err := scanClosureFile(DirSources("test_resources"), "pkg1.js")
+  if err != nil || len(res.Provides) != 1 || res.Provides[0] != "pkg1" {
+    t.Fatal("Invalid provides: ", res.Provides, err)
+  }
+
+  if len(res.Requires) != 2 ||
+     res.Requires[0] != (closureRequire{"pkg2", 4, depgraph.StrongEdge}) ||
+     res.Requires[1] != (closureRequire{"pkg3", 5, depgraph.StrongEdge}) {
+    t.Error("Invalid requires: ", res.Requires)
+  }
+}
+
+func TestScanRequireKinds(t *testing.T) {
+  src := "goog.module('a');\n" +
+         "const b = goog.require('b');\n" +
+         "const C = goog.requireType('c.C');\n" +
+         "goog.forwardDeclare('d');\n"
+  res, err := scanClosureFile(fstest.MapFS{"a.js": {Data: []byte(src)}},
+                              "a.js")
+  expected := []closureRequire{
+    {"b", 2, depgraph.StrongEdge},
+    {"c.C", 3, depgraph.TypeEdge},
+    {"d", 4, depgraph.ForwardDeclareEdge},
+  }
+  if err != nil || len(res.Requires) != len(expected) {
+    t.Fatal("Invalid requires: ", res.Requires, err)
+  }
+
+  for i, req := range res.Requires {
+    if req != expected[i] {
+      t.Error("Invalid require: ", req, expected[i])
+    }
+  }
+}
+
+func TestSourcesKey(t *testing.T) {
+  if sourcesKey(DirSources("test_resources")) !=
+     sourcesKey(DirSources("./test_resources/")) {
+    t.Error("Same directory has different keys.")
+  }
+
+  m := fstest.MapFS{}
+  if sourcesKey(m) == "" || sourcesKey(m) != sourcesKey(m) {
+    t.Error("Invalid key of a map: ", sourcesKey(m))
+  }
+
+  if sourcesKey(HttpSources(nil)) != "" {
+    t.Error("Unidentifiable sources have a key.")
+  }
+}
+
+func TestScanSourceWithoutModTime(t *testing.T) {
+  cc := NewCompilerWithSources(fstest.MapFS{
+    "a.js": {Data: []byte("goog.provide('a');")},
+  })
+  root := cc.sourceRoots()[0]
+  cc.scanSource(root, "a.js")
+  if _, unchanged, _ := cc.scanSource(root, "a.js"); unchanged {
+    t.Error("File without a modification time is unchanged.")
+  }
+  if len(cc.scans.entries) != 0 {
+    t.Error("Files without a modification time are cached.")
+  }
+}
+
+func newScanCompiler(files int, workers int) *Compiler {
+  m := fstest.MapFS{}
+  modTime := time.Unix(1000000000, 0)
+  for i := 0; i < files; i++ {
+    src := fmt.Sprintf("goog.provide('p%d');\n", i)
+    if i > 0 {
+      src += fmt.Sprintf("goog.require('p%d');\n", i - 1)
+    }
+    m[fmt.Sprintf("p%d.js", i)] = &fstest.MapFile{Data: []byte(src),
+                                                  ModTime: modTime}
+  }
+
+  cc := NewCompilerWithSources(m)
+  cc.ScanWorkers = workers
+  return cc
+}
+
+func TestParallelScan(t *testing.T) {
+  sequential := newScanCompiler(100, 1)
+  parallel := newScanCompiler(100, 8)
+  g1 := sequential.reloadDependencyGraph()
+  g2 := parallel.reloadDependencyGraph()
+  if len(g1.Nodes) != 100 || len(g2.Nodes) != 100 {
+    t.Fatal("Invalid packages: ", len(g1.Nodes), len(g2.Nodes))
+  }
+
+  deps1 := g1.GetDependenciesOfPackage("p99")
+  deps2 := g2.GetDependenciesOfPackage("p99")
+  for i := range deps1 {
+    if deps1[i].Pkg != deps2[i].Pkg {
+      t.Fatal("Parallel scan changes the order: ", deps1[i], deps2[i])
+    }
+  }
+
+  scan := parallel.Status().LastScan
+  if scan.Files != 100 || scan.Unchanged != 0 || scan.Workers != 8 {
+    t.Error("Invalid scan: ", scan)
+  }
+
+  parallel.reloadDependencyGraph()
+  if scan = parallel.Status().LastScan; scan.Unchanged != 100 {
+    t.Error("Unchanged files are scanned again: ", scan)
+  }
+}
+
+func BenchmarkScan(b *testing.B) {
+  for _, workers := range []int{1, 4} {
+    b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+      cc := newScanCompiler(2000, workers)
+      for i := 0; i < b.N; i++ {
+        // Without the scan cache, every file is read again.
+        cc.scans = nil
+        cc.reloadDependencyGraph()
+      }
+    })
+  }
+}
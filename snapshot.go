@@ -0,0 +1,68 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "sync"
+  "sync/atomic"
+
+  "github.com/soheilhy/glosure/depgraph"
+)
+
+// Holds the current dependency graph of a compiler. A published graph is
+// never modified: every rescan builds a new graph and swaps it in, so readers
+// need no lock and keep a consistent view while a rescan runs.
+type graphSnapshot struct {
+  // Holds a *scannedSources, or nil if no graph is built yet.
+  current atomic.Value
+  // Serializes the rescans started by readers.
+  mutex sync.Mutex
+}
+
+// The graph built by a scan, and the soy templates found by the same scan.
+type scannedSources struct {
+  graph *depgraph.DependencyGraph
+  templates []string
+}
+
+func newGraphSnapshot() *graphSnapshot {
+  s := &graphSnapshot{}
+  s.reset()
+  return s
+}
+
+// Returns the current graph, or nil if there is none.
+func (s *graphSnapshot) load() *depgraph.DependencyGraph {
+  if scanned := s.loadSources(); scanned != nil {
+    return scanned.graph
+  }
+  return nil
+}
+
+// Returns the current graph and templates, or nil if there is none.
+func (s *graphSnapshot) loadSources() *scannedSources {
+  scanned, _ := s.current.Load().(*scannedSources)
+  return scanned
+}
+
+func (s *graphSnapshot) store(g *depgraph.DependencyGraph,
+                              templates []string) {
+  s.current.Store(&scannedSources{g, templates})
+}
+
+// Drops the current graph, so the next reader rescans the sources.
+func (s *graphSnapshot) reset() {
+  s.current.Store((*scannedSources)(nil))
+}
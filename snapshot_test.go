@@ -0,0 +1,69 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "sync"
+  "testing"
+)
+
+func TestGraphSnapshots(t *testing.T) {
+  cc := NewCompilerWithSources(newMapSources())
+  g := cc.sharedGraph()
+  if cc.sharedGraph() != g {
+    t.Error("Graph is rebuilt for every reader.")
+  }
+
+  // Readers keep their snapshot while the sources are rescanned.
+  var wg sync.WaitGroup
+  for i := 0; i < 4; i++ {
+    wg.Add(2)
+    go func() {
+      defer wg.Done()
+      cc.reloadDependencyGraph()
+    }()
+    go func() {
+      defer wg.Done()
+      snapshot := cc.DependencyGraph()
+      if len(snapshot.GetDependenciesOfPackage("pkg1")) != 3 {
+        t.Error("Inconsistent snapshot: ", snapshot.Nodes)
+      }
+      cc.Status()
+    }()
+  }
+  wg.Wait()
+
+  if cc.sharedGraph() == g {
+    t.Error("Rescanned graph is not published.")
+  }
+
+  if len(g.GetDependenciesOfPackage("pkg1")) != 3 {
+    t.Error("Old snapshot is modified: ", g.Nodes)
+  }
+
+  // Copies handed out can be changed without changing the snapshot.
+  copied := cc.DependencyGraph()
+  copied.AddFile("added", "added.js")
+  copied.AddRequire("pkg1", "added", 1)
+  if _, ok := cc.sharedGraph().Nodes["added"]; ok ||
+     len(cc.sharedGraph().GetDependenciesOfPackage("pkg1")) != 3 {
+    t.Error("Snapshot is changed through a copy.")
+  }
+
+  cc.graph.reset()
+  if cc.graph.load() != nil || len(cc.DependencyGraph().Nodes) != 3 {
+    t.Error("Graph is not rebuilt after a reset.")
+  }
+}
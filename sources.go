@@ -0,0 +1,345 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "bytes"
+  "io"
+  "io/fs"
+  "io/ioutil"
+  "net/http"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+  "time"
+)
+
+// Sources in a directory on the local file system. The compiler passes such
+// sources to java without copying them.
+type dirSources struct {
+  fs.FS
+  dir string
+}
+
+// Returns the directory of the sources.
+func (d dirSources) Dir() string {
+  return d.dir
+}
+
+// Creates a source tree for the given directory.
+func DirSources(dir string) fs.FS {
+  return dirSources{os.DirFS(dir), dir}
+}
+
+// Creates a source tree for an http.FileSystem (e.g., http.Dir or the file
+// systems generated by asset embedding tools).
+func HttpSources(hfs http.FileSystem) fs.FS {
+  return httpSources{hfs}
+}
+
+type httpSources struct {
+  hfs http.FileSystem
+}
+
+func (h httpSources) Open(name string) (fs.File, error) {
+  if !fs.ValidPath(name) {
+    return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+  }
+
+  f, err := h.hfs.Open("/" + name)
+  if err != nil {
+    return nil, err
+  }
+  return httpFile{f}, nil
+}
+
+type httpFile struct {
+  http.File
+}
+
+func (f httpFile) ReadDir(n int) ([]fs.DirEntry, error) {
+  infos, err := f.Readdir(n)
+  entries := make([]fs.DirEntry, 0, len(infos))
+  for _, info := range infos {
+    entries = append(entries, fs.FileInfoToDirEntry(info))
+  }
+  return entries, err
+}
+
+// Creates a source tree that overlays the given trees. Files in the earlier
+// trees shadow the files with the same name in the later ones, and directories
+// are merged.
+func OverlaySources(layers ...fs.FS) fs.FS {
+  return overlaySources(layers)
+}
+
+type overlaySources []fs.FS
+
+func (o overlaySources) Open(name string) (fs.File, error) {
+  var firstErr error
+  for _, layer := range o {
+    f, err := layer.Open(name)
+    if err == nil {
+      return f, nil
+    }
+
+    if firstErr == nil {
+      firstErr = err
+    }
+  }
+
+  if firstErr == nil {
+    firstErr = &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+  }
+  return nil, firstErr
+}
+
+func (o overlaySources) ReadDir(name string) ([]fs.DirEntry, error) {
+  var firstErr error
+  found := false
+  merged := make(map[string]fs.DirEntry)
+  for _, layer := range o {
+    entries, err := fs.ReadDir(layer, name)
+    if err != nil {
+      if firstErr == nil {
+        firstErr = err
+      }
+      continue
+    }
+
+    found = true
+    for _, e := range entries {
+      if _, ok := merged[e.Name()]; !ok {
+        merged[e.Name()] = e
+      }
+    }
+  }
+
+  if !found {
+    return nil, firstErr
+  }
+
+  entries := make([]fs.DirEntry, 0, len(merged))
+  for _, e := range merged {
+    entries = append(entries, e)
+  }
+  sort.Slice(entries, func(i, j int) bool {
+    return entries[i].Name() < entries[j].Name()
+  })
+  return entries, nil
+}
+
+// A read-only view of the files in an OutputStore. Listing directories is not
+// supported.
+type storeSources struct {
+  store OutputStore
+}
+
+func (s storeSources) Open(name string) (fs.File, error) {
+  info, err := s.store.Stat(name)
+  if err != nil {
+    return nil, err
+  }
+
+  content, err := s.store.ReadFile(name)
+  if err != nil {
+    return nil, err
+  }
+  return &memorySourceFile{bytes.NewReader(content), info}, nil
+}
+
+type memorySourceFile struct {
+  *bytes.Reader
+  info os.FileInfo
+}
+
+func (f *memorySourceFile) Stat() (fs.FileInfo, error) {
+  return f.info, nil
+}
+
+func (f *memorySourceFile) Close() error {
+  return nil
+}
+
+// SourceRoot is a source tree mounted under a URL prefix.
+type SourceRoot struct {
+  // Name of the root used in reports (e.g., "closure-library").
+  Name string
+  // Sources of the root.
+  Sources fs.FS
+  // URL prefix of the root (e.g., "closure/"). Files of the root are named by
+  // this prefix in the dependency graph, and are served under it when
+  // Compiler.ServeSources is set.
+  Prefix string
+  // deps.js files in Sources whose goog.addDependency calls list the files of
+  // the root (e.g., "goog/deps.js"). A root with deps files is not scanned,
+  // and its files are never written.
+  DepsFiles []string
+  // Directory in Sources against which the paths in DepsFiles are resolved.
+  // Uses the directory of every deps file by default, as Closure does for
+  // base.js.
+  DepsBase string
+}
+
+// Returns all source roots in the order of priority, starting with
+// Compiler.Sources.
+func (cc *Compiler) sourceRoots() []SourceRoot {
+  roots := []SourceRoot{{Name: "main", Sources: cc.Sources}}
+  for _, root := range cc.Roots {
+    root.Prefix = cleanName(root.Prefix)
+    if root.Prefix != "" {
+      root.Prefix += "/"
+    }
+    roots = append(roots, root)
+  }
+  return roots
+}
+
+// Returns the root providing the named source and the name of the source in
+// that root.
+func (cc *Compiler) resolveSource(name string) (SourceRoot, string, bool) {
+  for _, root := range cc.sourceRoots() {
+    if !strings.HasPrefix(name, root.Prefix) {
+      continue
+    }
+
+    rel := name[len(root.Prefix):]
+    if _, err := fs.Stat(root.Sources, rel); err == nil {
+      return root, rel, true
+    }
+  }
+  return SourceRoot{}, "", false
+}
+
+// Source roots mounted under their prefixes.
+type mountedSources struct {
+  cc *Compiler
+}
+
+func (m mountedSources) Open(name string) (fs.File, error) {
+  root, rel, ok := m.cc.resolveSource(name)
+  if !ok {
+    return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+  }
+  return root.Sources.Open(rel)
+}
+
+// Returns all source roots mounted under their prefixes.
+func (cc *Compiler) mountedSources() fs.FS {
+  return mountedSources{cc}
+}
+
+// Calls fn for every file in the source roots, in the order of priority.
+// Roots listed by deps files and ignored files are skipped (see walkRoot).
+func (cc *Compiler) walkSources(fn func(root SourceRoot, name string)) {
+  for _, root := range cc.sourceRoots() {
+    if len(root.DepsFiles) == 0 {
+      cc.walkRoot(root, fn)
+    }
+  }
+}
+
+// Returns the tree from which the dependency graph is built: the source roots,
+// plus the JavaScript generated from soy templates in Compiler.Outputs.
+func (cc *Compiler) sourceTree() fs.FS {
+  return OverlaySources(cc.mountedSources(), storeSources{cc.Outputs})
+}
+
+// Serves a source file as is.
+func (cc *Compiler) serveSource(res http.ResponseWriter, req *http.Request) {
+  name := cleanName(req.URL.Path)
+  f, err := cc.mountedSources().Open(name)
+  if err != nil {
+    cc.ErrorHandler(res, req)
+    return
+  }
+  defer f.Close()
+
+  info, err := f.Stat()
+  if err != nil || info.IsDir() {
+    cc.ErrorHandler(res, req)
+    return
+  }
+
+  content, err := ioutil.ReadAll(f)
+  if err != nil {
+    cc.ErrorHandler(res, req)
+    return
+  }
+
+  res.Header().Set("Cache-Control", "no-cache")
+  http.ServeContent(res, req, name, info.ModTime(), bytes.NewReader(content))
+}
+
+// Returns the modification time of a file in the source tree.
+func (cc *Compiler) sourceModTime(name string) (time.Time, error) {
+  info, err := fs.Stat(cc.sourceTree(), name)
+  if err != nil {
+    return time.Time{}, err
+  }
+  return info.ModTime(), nil
+}
+
+// Returns local paths for the given names in the source tree, as needed by
+// java. Files in a DirSources tree are used in place, and the rest are written
+// into tmpDir.
+func (cc *Compiler) localSourcePaths(names []string,
+                                     tmpDir string) ([]string, error) {
+  paths := make([]string, 0, len(names))
+  for _, name := range names {
+    root, rel, ok := cc.resolveSource(name)
+    if d, isDir := root.Sources.(interface{ Dir() string }); ok && isDir {
+      paths = append(paths, filepath.Join(d.Dir(), filepath.FromSlash(rel)))
+      continue
+    }
+
+    content, err := fs.ReadFile(cc.sourceTree(), name)
+    if err != nil {
+      return nil, err
+    }
+
+    localPath := filepath.Join(tmpDir, "src", filepath.FromSlash(name))
+    err = os.MkdirAll(filepath.Dir(localPath), 0755)
+    if err != nil {
+      return nil, err
+    }
+
+    err = ioutil.WriteFile(localPath, content, 0644)
+    if err != nil {
+      return nil, err
+    }
+    paths = append(paths, localPath)
+  }
+  return paths, nil
+}
+
+// Reads and concatenates the given files in the source tree.
+func (cc *Compiler) readSources(names []string) ([]byte, error) {
+  var buffer bytes.Buffer
+  for _, name := range names {
+    f, err := cc.sourceTree().Open(name)
+    if err != nil {
+      return nil, err
+    }
+
+    _, err = io.Copy(&buffer, f)
+    f.Close()
+    if err != nil {
+      return nil, err
+    }
+  }
+  return buffer.Bytes(), nil
+}
@@ -0,0 +1,202 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "io/fs"
+  "io/ioutil"
+  "net/http"
+  "net/http/httptest"
+  "path/filepath"
+  "strings"
+  "testing"
+  "testing/fstest"
+)
+
+func TestHttpSources(t *testing.T) {
+  sources := HttpSources(http.Dir("test_resources"))
+  content, err := fs.ReadFile(sources, "pkg2.js")
+  if err != nil || !strings.Contains(string(content), "goog.provide('pkg2')") {
+    t.Error("Cannot read from an http.FileSystem: ", err)
+  }
+
+  entries, err := fs.ReadDir(sources, ".")
+  if err != nil || len(entries) == 0 {
+    t.Error("Cannot list an http.FileSystem: ", err)
+  }
+}
+
+func TestOverlaySources(t *testing.T) {
+  sources := OverlaySources(
+    fstest.MapFS{
+      "a.js": {Data: []byte("upper")},
+      "lib/b.js": {Data: []byte("upper")},
+    },
+    fstest.MapFS{
+      "a.js": {Data: []byte("lower")},
+      "lib/c.js": {Data: []byte("lower")},
+    })
+
+  content, err := fs.ReadFile(sources, "a.js")
+  if err != nil || string(content) != "upper" {
+    t.Error("Upper layer does not shadow the lower layer: ", string(content))
+  }
+
+  content, err = fs.ReadFile(sources, "lib/c.js")
+  if err != nil || string(content) != "lower" {
+    t.Error("Cannot read from the lower layer: ", err)
+  }
+
+  entries, err := fs.ReadDir(sources, "lib")
+  if err != nil || len(entries) != 2 {
+    t.Error("Directories are not merged: ", entries, err)
+  }
+}
+
+func newMapSources() fstest.MapFS {
+  sources := fstest.MapFS{}
+  for _, name := range []string{"pkg1.js", "pkg2.js", "pkg3.js"} {
+    content, _ := ioutil.ReadFile(filepath.Join("test_resources", name))
+    sources["lib/" + name] = &fstest.MapFile{Data: content}
+  }
+  return sources
+}
+
+func TestDependencyGraphWithSources(t *testing.T) {
+  cc := NewCompilerWithSources(newMapSources())
+  g := cc.reloadDependencyGraph()
+
+  deps := g.GetDependenciesOfPackage("pkg1")
+  expected := []string{"lib/pkg3.js", "lib/pkg2.js", "lib/pkg1.js"}
+  if len(deps) != len(expected) {
+    t.Fatal("Invalid dependencies: ", deps)
+  }
+
+  for i, dep := range deps {
+    if dep.Path != expected[i] {
+      t.Error("Invalid dependency: ", dep.Path, expected[i])
+    }
+  }
+}
+
+func TestLocalSourcePaths(t *testing.T) {
+  tmpDir := t.TempDir()
+  cc := NewCompilerWithSources(newMapSources())
+  paths, err := cc.localSourcePaths([]string{"lib/pkg2.js"}, tmpDir)
+  if err != nil || !strings.HasPrefix(paths[0], tmpDir) {
+    t.Fatal("Sources are not copied into the temporary directory: ", paths)
+  }
+
+  content, _ := ioutil.ReadFile(paths[0])
+  if !strings.Contains(string(content), "goog.provide('pkg2')") {
+    t.Error("Invalid copied source: ", string(content))
+  }
+
+  dirCompiler := NewCompiler("test_resources")
+  paths, err = dirCompiler.localSourcePaths([]string{"pkg2.js"}, tmpDir)
+  if err != nil || paths[0] != filepath.Join("test_resources", "pkg2.js") {
+    t.Error("Sources in a directory are copied: ", paths)
+  }
+}
+
+func TestReadSources(t *testing.T) {
+  cc := NewCompilerWithSources(fstest.MapFS{
+    "a.js": {Data: []byte("var a;")},
+    "b.js": {Data: []byte("var b;")},
+  })
+  cc.Outputs.WriteFile("c.soy.js", []byte("var c;"))
+
+  src, err := cc.readSources([]string{"a.js", "c.soy.js", "b.js"})
+  if err != nil || string(src) != "var a;var c;var b;" {
+    t.Error("Invalid concatenated sources: ", string(src), err)
+  }
+}
+
+func newRootsCompiler() *Compiler {
+  cc := NewCompilerWithSources(fstest.MapFS{
+    "app.js": {Data: []byte("goog.provide('app');\ngoog.require('goog.dom');")},
+  })
+  cc.Roots = []SourceRoot{
+    {
+      Name: "closure-library",
+      Sources: fstest.MapFS{
+        "goog/dom.js": {Data: []byte("goog.provide('goog.dom');")},
+      },
+      Prefix: "/closure/",
+    },
+    {
+      Name: "third-party",
+      Sources: fstest.MapFS{
+        "dom.js": {Data: []byte("goog.provide('goog.dom');")},
+      },
+      Prefix: "third_party",
+    },
+  }
+  return cc
+}
+
+func TestSourceRoots(t *testing.T) {
+  cc := newRootsCompiler()
+  g := cc.reloadDependencyGraph()
+
+  deps := g.GetDependenciesOfPackage("app")
+  expected := []string{"closure/goog/dom.js", "app.js"}
+  if len(deps) != len(expected) {
+    t.Fatal("Invalid dependencies: ", deps)
+  }
+
+  for i, dep := range deps {
+    if dep.Path != expected[i] {
+      t.Error("Invalid dependency: ", dep.Path, expected[i])
+    }
+  }
+
+  if _, ok := g.Nodes["goog.dom"]; !ok {
+    t.Error("Namespace of the first root is dropped.")
+  }
+
+  src, err := cc.readSources([]string{"third_party/dom.js"})
+  if err != nil || !strings.Contains(string(src), "goog.dom") {
+    t.Error("Cannot read sources of a prefixed root: ", string(src), err)
+  }
+}
+
+func TestServeSources(t *testing.T) {
+  cc := newRootsCompiler()
+  cc.ServeSources = true
+
+  req := httptest.NewRequest("GET", "/closure/goog/dom.js", nil)
+  res := httptest.NewRecorder()
+  ServeHttp(res, req, cc)
+  if res.Code != http.StatusOK ||
+     res.Body.String() != "goog.provide('goog.dom');" {
+    t.Error("Source is not served: ", res.Code, res.Body.String())
+  }
+
+  req = httptest.NewRequest("GET", "/closure/missing.js", nil)
+  res = httptest.NewRecorder()
+  ServeHttp(res, req, cc)
+  if res.Code != http.StatusNotFound {
+    t.Error("Missing source is served: ", res.Code)
+  }
+
+  cc.ServeSources = false
+  req = httptest.NewRequest("GET", "/closure/goog/dom.js", nil)
+  res = httptest.NewRecorder()
+  ServeHttp(res, req, cc)
+  if res.Code != http.StatusNotFound {
+    t.Error("Source is served when ServeSources is not set: ", res.Code)
+  }
+}
@@ -0,0 +1,199 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "errors"
+  "fmt"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "strings"
+  "time"
+
+  "github.com/golang/glog"
+)
+
+func (cc *Compiler) isSoyTemplate(path string) bool {
+  return strings.HasSuffix(path, cc.SoySuffix)
+}
+
+// Returns the name of the JavaScript generated for a template in
+// Compiler.Outputs.
+func (cc *Compiler) getGeneratedTemplateName(soyName string) string {
+  return soyName + cc.SourceSuffix
+}
+
+// Returns the soy templates in the source roots.
+func (cc *Compiler) walkTemplates() []string {
+  templates := []string{}
+  cc.walkSources(func(root SourceRoot, name string) {
+    if cc.isSoyTemplate(name) {
+      templates = append(templates, name)
+    }
+  })
+  return templates
+}
+
+// Returns the templates whose generated JavaScript is missing or out of date.
+func (cc *Compiler) findStaleTemplates(templates []string) []string {
+  stale := []string{}
+  for _, name := range templates {
+    if !cc.isUpToDate(name, cc.getGeneratedTemplateName(name)) {
+      stale = append(stale, name)
+    }
+  }
+  return stale
+}
+
+// Whether the templates that the target depends on are compiled, and their
+// generated JavaScript is not newer than the output of the target compiled at
+// modTime. Any stale template makes every target stale, since the templates
+// are compiled with the next target. Only the templates found by the last
+// scan are checked, so the source roots are not walked again.
+func (cc *Compiler) templatesAreUpToDate(path string,
+                                         modTime time.Time) bool {
+  if cc.SoyCompilerJarPath == "" {
+    return true
+  }
+
+  pkgs, err := getClosurePackage(cc.mountedSources(),
+                                 cc.getSourceJavascriptName(path))
+  if err != nil {
+    return true
+  }
+
+  scanned := cc.scannedSources()
+  if len(cc.findStaleTemplates(scanned.templates)) != 0 {
+    return false
+  }
+
+  deps, err := cc.getDependencies(scanned.graph, pkgs)
+  if err != nil {
+    return false
+  }
+
+  for _, dep := range deps {
+    if !strings.HasSuffix(dep.Path, cc.SoySuffix + cc.SourceSuffix) {
+      continue
+    }
+
+    stat, err := cc.Outputs.Stat(dep.Path)
+    if err != nil || stat.ModTime().After(modTime) {
+      return false
+    }
+  }
+  return true
+}
+
+// Returns the arguments of the soy compiler for templates relative to
+// inputPrefix. The outputs are written into outDir.
+func (cc *Compiler) getSoyCompilerArgs(inputPrefix string, outDir string,
+                                       templates []string) []string {
+  return []string{
+    "-jar", cc.SoyCompilerJarPath,
+    "--shouldProvideRequireSoyNamespaces",
+    "--shouldGenerateJsdoc",
+    "--inputPrefix", inputPrefix,
+    "--outputPathFormat", outDir + "/{INPUT_DIRECTORY}/{INPUT_FILE_NAME}" +
+                          cc.SourceSuffix,
+    "--srcs", strings.Join(templates, ","),
+  }
+}
+
+// Compiles the soy templates that are changed since their last compilation
+// into Compiler.Outputs. The generated JavaScript files provide the template
+// namespaces, and are picked up by the dependency graph. Returns whether any
+// template is compiled.
+func (cc *Compiler) compileTemplates() (bool, error) {
+  if cc.SoyCompilerJarPath == "" {
+    return false, nil
+  }
+
+  stale := cc.findStaleTemplates(cc.walkTemplates())
+  if len(stale) == 0 {
+    return false, nil
+  }
+
+  glog.V(1).Info("Compiling soy templates: ", strings.Join(stale, ", "))
+  tmpDir, err := ioutil.TempDir("", "glosure")
+  if err != nil {
+    return false, err
+  }
+  defer os.RemoveAll(tmpDir)
+
+  // The soy compiler needs a common input prefix for its templates. Templates
+  // in a directory are compiled in place with the directory as the prefix,
+  // and the rest are copied into tmpDir.
+  type soyInput struct {
+    name string
+    src string
+  }
+
+  inputs := make(map[string][]soyInput)
+  copied := []string{}
+  for _, t := range stale {
+    root, rel, _ := cc.resolveSource(t)
+    d, ok := root.Sources.(interface{ Dir() string })
+    if !ok {
+      copied = append(copied, t)
+      continue
+    }
+
+    prefix := d.Dir() + string(filepath.Separator)
+    inputs[prefix] = append(inputs[prefix], soyInput{t, rel})
+  }
+
+  if len(copied) != 0 {
+    _, err = cc.localSourcePaths(copied, tmpDir)
+    if err != nil {
+      return false, err
+    }
+
+    prefix := filepath.Join(tmpDir, "src") + string(filepath.Separator)
+    for _, t := range copied {
+      inputs[prefix] = append(inputs[prefix], soyInput{t, t})
+    }
+  }
+
+  srcHashes := cc.hashSources(stale...)
+  group := 0
+  for prefix, templates := range inputs {
+    group++
+    outDir := filepath.Join(tmpDir, fmt.Sprintf("out%d", group))
+    srcs := make([]string, 0, len(templates))
+    for _, t := range templates {
+      srcs = append(srcs, t.src)
+    }
+
+    _, err = cc.runJava(cc.getSoyCompilerArgs(prefix, outDir, srcs))
+    if err != nil {
+      return false, errors.New("Cannot compile soy templates: " + err.Error())
+    }
+
+    for _, t := range templates {
+      genName := cc.getGeneratedTemplateName(t.name)
+      genPath := filepath.Join(outDir, filepath.FromSlash(t.src) +
+                                       cc.SourceSuffix)
+      err = cc.storeOutput(genName, genPath)
+      if err != nil {
+        return false, err
+      }
+      cc.recordSources(genName, map[string]string{t.name: srcHashes[t.name]})
+    }
+  }
+
+  return true, nil
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "testing"
+  "time"
+)
+
+func TestFindStaleTemplates(t *testing.T) {
+  cc := NewCompiler("./test_resources")
+  stale := cc.findStaleTemplates(cc.walkTemplates())
+  if len(stale) != 1 || stale[0] != "templates.soy" {
+    t.Error("Invalid stale templates: ", stale)
+  }
+}
+
+func TestCompileTemplatesWithoutJar(t *testing.T) {
+  cc := NewCompiler("./test_resources")
+  compiled, err := cc.compileTemplates()
+  if compiled || err != nil {
+    t.Error("Compiled templates without a soy compiler: ", err)
+  }
+}
+
+func TestSoyCompilerArgs(t *testing.T) {
+  cc := NewCompiler("./test_resources")
+  cc.SoyCompilerJarPath = "soy.jar"
+  args := cc.getSoyCompilerArgs("/src/", "/out",
+                                []string{"a.soy", "b/c.soy"})
+  if args[len(args) - 1] != "a.soy,b/c.soy" {
+    t.Error("Invalid soy sources: ", args)
+  }
+
+  if args[len(args) - 3] != "/out/{INPUT_DIRECTORY}/{INPUT_FILE_NAME}.js" {
+    t.Error("Invalid soy output path format: ", args)
+  }
+}
+
+func TestEditedTemplateMakesTargetStale(t *testing.T) {
+  root := newCompiledRoot(t)
+  ioutil.WriteFile(filepath.Join(root, "app.js"),
+                   []byte("goog.provide('app');\ngoog.require('tmpl');\n"),
+                   0644)
+  ioutil.WriteFile(filepath.Join(root, "t.soy"),
+                   []byte("{namespace tmpl}\n"), 0644)
+  ioutil.WriteFile(filepath.Join(root, "t.soy.js"),
+                   []byte("goog.provide('tmpl');\n"), 0644)
+
+  // The source, the template and its generated JavaScript are older than
+  // the output.
+  past := time.Now().Add(-2 * time.Hour)
+  for _, name := range []string{"app.js", "t.soy", "t.soy.js"} {
+    os.Chtimes(filepath.Join(root, name), past, past)
+  }
+
+  cc := NewCompiler(root)
+  cc.SoyCompilerJarPath = "soy.jar"
+  if !cc.jsIsAlreadyCompiled("/app.min.js") {
+    t.Fatal("Target with compiled templates is stale.")
+  }
+  scanned := cc.graph.loadSources()
+
+  // An edited template needs a compile.
+  now := time.Now()
+  os.Chtimes(filepath.Join(root, "t.soy"), now, now)
+  if cc.jsIsAlreadyCompiled("/app.min.js") {
+    t.Error("Edited template does not make the target stale.")
+  }
+
+  // A template compiled after the target, e.g., with another target.
+  later := now.Add(time.Minute)
+  os.Chtimes(filepath.Join(root, "t.soy.js"), later, later)
+  if cc.jsIsAlreadyCompiled("/app.min.js") {
+    t.Error("Recompiled template does not make the target stale.")
+  }
+
+  // The templates of the first scan are checked again without a rescan.
+  if cc.graph.loadSources() != scanned || len(scanned.templates) != 1 {
+    t.Error("Sources are scanned again to check the templates.")
+  }
+}
@@ -0,0 +1,447 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "encoding/json"
+  "html/template"
+  "net/http"
+  "os/exec"
+  "regexp"
+  "sort"
+  "sync"
+  "time"
+
+  "github.com/golang/glog"
+  "github.com/soheilhy/glosure/depgraph"
+)
+
+// Status is a snapshot of what a compiler knows about its sources and targets.
+type Status struct {
+  // Name of the configuration in a Registry, if any.
+  Name string `json:"name,omitempty"`
+  // Compiler backend: "jar" or "api".
+  Backend string `json:"backend"`
+  CompilerVersion string `json:"compilerVersion"`
+  // Closure namespaces discovered in the source roots.
+  Packages []PackageStatus `json:"packages"`
+  // Namespaces that no other namespace requires.
+  EntryPoints []string `json:"entryPoints"`
+  // Namespaces provided by more than one file.
+  Conflicts []depgraph.Conflict `json:"conflicts"`
+  Targets []TargetStatus `json:"targets"`
+  // Errors and warnings of the last compilation of every target.
+  Diagnostics []Diagnostic `json:"diagnostics"`
+  // Requests served from compiled outputs (hits) or compiled first (misses).
+  OutputCache CacheStatus `json:"outputCache"`
+  // Source files scanned from the scan cache (hits) or read (misses).
+  ScanCache CacheStatus `json:"scanCache"`
+  // The last scan of the source roots.
+  LastScan ScanStatus `json:"lastScan"`
+}
+
+type PackageStatus struct {
+  Namespace string `json:"namespace"`
+  File string `json:"file"`
+}
+
+// TargetStatus is the result of the last compilation of a target.
+type TargetStatus struct {
+  Target string `json:"target"`
+  Backend string `json:"backend"`
+  LastCompiled time.Time `json:"lastCompiled"`
+  // Duration of the compilation in nanoseconds.
+  Duration time.Duration `json:"duration"`
+  Error string `json:"error,omitempty"`
+  Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// ScanStatus is the result of a scan of the source roots.
+type ScanStatus struct {
+  Time time.Time `json:"time"`
+  // Duration of the scan in nanoseconds.
+  Duration time.Duration `json:"duration"`
+  // Files found in the source roots, and those unchanged since their last
+  // scan.
+  Files int `json:"files"`
+  Unchanged int `json:"unchanged"`
+  // Number of files scanned at once.
+  Workers int `json:"workers"`
+}
+
+type CacheStatus struct {
+  Hits int64 `json:"hits"`
+  Misses int64 `json:"misses"`
+  HitRate float64 `json:"hitRate"`
+}
+
+func newCacheStatus(hits int64, misses int64) CacheStatus {
+  s := CacheStatus{Hits: hits, Misses: misses}
+  if hits + misses != 0 {
+    s.HitRate = float64(hits) / float64(hits + misses)
+  }
+  return s
+}
+
+// Compilation statistics of a compiler.
+type compileStats struct {
+  targets map[string]TargetStatus
+  hits int64
+  misses int64
+  // Compiler versions keyed by the jar path.
+  versions map[string]string
+  lastScan ScanStatus
+  mutex sync.Mutex
+}
+
+func newCompileStats() *compileStats {
+  return &compileStats{
+    targets: make(map[string]TargetStatus),
+    versions: make(map[string]string),
+  }
+}
+
+func (s *compileStats) recordHit() {
+  if s == nil {
+    return
+  }
+
+  s.mutex.Lock()
+  s.hits++
+  s.mutex.Unlock()
+}
+
+func (s *compileStats) recordMiss() {
+  if s == nil {
+    return
+  }
+
+  s.mutex.Lock()
+  s.misses++
+  s.mutex.Unlock()
+}
+
+// Records and logs a scan of the source roots.
+func (cc *Compiler) recordScan(scan ScanStatus) {
+  glog.Infof("Scanned %d files (%d unchanged) in %v with %d workers.",
+             scan.Files, scan.Unchanged, scan.Duration, scan.Workers)
+  if cc.stats == nil {
+    return
+  }
+
+  cc.stats.mutex.Lock()
+  cc.stats.lastScan = scan
+  cc.stats.mutex.Unlock()
+}
+
+func (cc *Compiler) backend() string {
+  if cc.UseClosureApi {
+    return "api"
+  }
+  return "jar"
+}
+
+// Records the result of compiling a target. Returns err as a
+// *CompilationError carrying the diagnostics, or nil if err is nil.
+func (cc *Compiler) recordCompile(target string, backend string,
+                                  start time.Time, diags []Diagnostic,
+                                  err error) error {
+  if diags == nil {
+    diags = []Diagnostic{}
+  }
+
+  if err != nil {
+    if _, ok := err.(*CompilationError); !ok {
+      err = &CompilationError{Target: target, Diagnostics: diags, Err: err}
+    }
+  }
+
+  if cc.stats == nil {
+    return err
+  }
+
+  status := TargetStatus{
+    Target: cleanName(target),
+    Backend: backend,
+    LastCompiled: start,
+    Duration: time.Since(start),
+    Diagnostics: diags,
+  }
+
+  if err != nil {
+    status.Error = err.Error()
+  }
+
+  cc.stats.mutex.Lock()
+  cc.stats.targets[status.Target] = status
+  cc.stats.mutex.Unlock()
+  return err
+}
+
+var compilerVersionRegex = regexp.MustCompile(`Version: (\S+)`)
+
+// Returns the version of the closure compiler. The jar is run once per path,
+// without holding the stats lock, so compilations are not recorded late.
+func (cc *Compiler) compilerVersion() string {
+  if cc.UseClosureApi {
+    return "closure-compiler.appspot.com"
+  }
+
+  if cc.CompilerJarPath == "" || cc.stats == nil {
+    return "unknown"
+  }
+
+  jar := cc.CompilerJarPath
+  cc.stats.mutex.Lock()
+  version, ok := cc.stats.versions[jar]
+  cc.stats.mutex.Unlock()
+  if ok {
+    return version
+  }
+
+  version = "unknown"
+  out, err := exec.Command("java", "-jar", jar, "--version").Output()
+  if m := compilerVersionRegex.FindSubmatch(out); err == nil && m != nil {
+    version = string(m[1])
+  }
+
+  cc.stats.mutex.Lock()
+  cc.stats.versions[jar] = version
+  cc.stats.mutex.Unlock()
+  return version
+}
+
+// Returns the status of the compiler. The dependency graph is loaded if it is
+// not loaded yet.
+func (cc *Compiler) Status() Status {
+  status := Status{
+    Backend: cc.backend(),
+    CompilerVersion: cc.compilerVersion(),
+    Packages: []PackageStatus{},
+    EntryPoints: []string{},
+    Conflicts: []depgraph.Conflict{},
+    Targets: []TargetStatus{},
+    Diagnostics: []Diagnostic{},
+  }
+
+  g := *cc.sharedGraph()
+  status.Diagnostics = append(status.Diagnostics, cycleDiagnostics(g, nil)...)
+  status.Diagnostics = append(status.Diagnostics,
+                              cc.conflictDiagnostics(g, nil)...)
+  status.Conflicts = append(status.Conflicts, g.Conflicts()...)
+
+  status.EntryPoints = append(status.EntryPoints, g.Roots()...)
+
+  for pkg, node := range g.Nodes {
+    status.Packages = append(status.Packages, PackageStatus{pkg, node.Path})
+  }
+
+  sort.Slice(status.Packages, func(i, j int) bool {
+    return status.Packages[i].Namespace < status.Packages[j].Namespace
+  })
+
+  if cc.stats != nil {
+    cc.stats.mutex.Lock()
+    for _, target := range cc.stats.targets {
+      status.Targets = append(status.Targets, target)
+      status.Diagnostics = append(status.Diagnostics, target.Diagnostics...)
+    }
+    status.OutputCache = newCacheStatus(cc.stats.hits, cc.stats.misses)
+    status.LastScan = cc.stats.lastScan
+    cc.stats.mutex.Unlock()
+  }
+
+  sort.Slice(status.Targets, func(i, j int) bool {
+    return status.Targets[i].Target < status.Targets[j].Target
+  })
+
+  if cc.scans != nil {
+    cc.scans.mutex.Lock()
+    status.ScanCache = newCacheStatus(cc.scans.hits, cc.scans.misses)
+    cc.scans.mutex.Unlock()
+  }
+  return status
+}
+
+const statusHtml = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Glosure status</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+td, th { border: 1px solid #ccc; padding: 2px 8px; text-align: left; }
+.error { color: #c00; }
+.warning { color: #a60; }
+</style>
+</head>
+<body>
+{{range .}}
+<h1>{{if .Name}}{{.Name}}{{else}}Glosure{{end}}</h1>
+<p>Backend: {{.Backend}}, compiler version: {{.CompilerVersion}}</p>
+<p>Output cache: {{.OutputCache.Hits}} hits, {{.OutputCache.Misses}} misses.
+Scan cache: {{.ScanCache.Hits}} hits, {{.ScanCache.Misses}} misses.</p>
+<p>Last scan: {{.LastScan.Files}} files ({{.LastScan.Unchanged}} unchanged)
+in {{.LastScan.Duration}} with {{.LastScan.Workers}} workers.</p>
+
+<h2>Targets</h2>
+<table>
+<tr><th>Target</th><th>Backend</th><th>Last compiled</th><th>Duration</th>
+<th>Error</th></tr>
+{{range .Targets}}
+<tr><td>{{.Target}}</td><td>{{.Backend}}</td>
+<td>{{.LastCompiled.Format "2006-01-02 15:04:05"}}</td><td>{{.Duration}}</td>
+<td class="error">{{.Error}}</td></tr>
+{{end}}
+</table>
+
+<h2>Diagnostics</h2>
+<ul>
+{{range .Diagnostics}}<li class="{{.Severity}}">{{.String}}</li>
+{{else}}<li>None</li>
+{{end}}
+</ul>
+
+<h2>Conflicts</h2>
+<ul>
+{{range .Conflicts}}<li>{{.Pkg}}:
+{{range $i, $f := .Paths}}{{if $i}}, {{end}}{{$f}}{{end}}</li>
+{{else}}<li>None</li>
+{{end}}
+</ul>
+
+<h2>Entry points</h2>
+<ul>
+{{range .EntryPoints}}<li>{{.}}</li>
+{{end}}
+</ul>
+
+<h2>Packages</h2>
+<table>
+<tr><th>Namespace</th><th>File</th></tr>
+{{range .Packages}}<tr><td>{{.Namespace}}</td><td>{{.File}}</td></tr>
+{{end}}
+</table>
+{{end}}
+</body>
+</html>
+`
+
+var statusTemplate = template.Must(template.New("status").Parse(statusHtml))
+
+// Writes the statuses as HTML, or as JSON if "format=json" is passed.
+func serveStatus(res http.ResponseWriter, req *http.Request,
+                 statuses []Status) {
+  res.Header().Set("Cache-Control", "no-cache")
+  if req.URL.Query().Get("format") == "json" {
+    res.Header().Set("Content-Type", "application/json")
+    enc := json.NewEncoder(res)
+    enc.SetIndent("", "  ")
+    if err := enc.Encode(statuses); err != nil {
+      glog.Error("Cannot encode the status: ", err)
+    }
+    return
+  }
+
+  res.Header().Set("Content-Type", "text/html; charset=utf-8")
+  if err := statusTemplate.Execute(res, statuses); err != nil {
+    glog.Error("Cannot render the status: ", err)
+  }
+}
+
+// Maximum number of chains of requires served with "all=true".
+const maxServedRequirePaths = 100
+
+// Writes, as JSON, the chains of requires from the "entry" namespace to the
+// "why" namespace of the request. Up to maxServedRequirePaths chains are
+// written if "all=true" is passed, otherwise only the shortest.
+func serveRequirePaths(res http.ResponseWriter, req *http.Request,
+                       cc *Compiler) {
+  query := req.URL.Query()
+  limit := 1
+  if query.Get("all") == "true" {
+    limit = maxServedRequirePaths
+  }
+
+  paths, err := cc.RequirePaths(query.Get("entry"), query.Get("why"), limit)
+  if err != nil {
+    http.Error(res, err.Error(), http.StatusBadRequest)
+    return
+  }
+
+  res.Header().Set("Cache-Control", "no-cache")
+  res.Header().Set("Content-Type", "application/json")
+  enc := json.NewEncoder(res)
+  enc.SetIndent("", "  ")
+  if err := enc.Encode(paths); err != nil {
+    glog.Error("Cannot encode the require paths: ", err)
+  }
+}
+
+// Creates an http.Handler serving the status of the compiler as HTML, or as
+// JSON if the request has "format=json". The handler can be mounted under any
+// path:
+//
+//   http.Handle("/_glosure/status", glosure.StatusServer(&cc))
+//
+// "?entry=app&why=goog.dom" explains why app depends on goog.dom instead (see
+// Compiler.RequirePaths).
+func StatusServer(cc *Compiler) http.Handler {
+  return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+    if req.URL.Query().Get("why") != "" {
+      serveRequirePaths(res, req, cc)
+      return
+    }
+    serveStatus(res, req, []Status{cc.Status()})
+  })
+}
+
+// Returns the status of every configuration in the registry.
+func (r *Registry) Status() []Status {
+  r.mutex.RLock()
+  entries := append([]*registryEntry{}, r.entries...)
+  r.mutex.RUnlock()
+
+  statuses := make([]Status, 0, len(entries))
+  for _, e := range entries {
+    status := e.cc.Status()
+    status.Name = e.name
+    statuses = append(statuses, status)
+  }
+  return statuses
+}
+
+// Creates an http.Handler serving the status of every configuration in the
+// registry, in the same formats as StatusServer. Require paths are explained
+// for the configuration named by "config" (e.g.,
+// "?config=admin&entry=admin&why=goog.dom").
+func (r *Registry) StatusServer() http.Handler {
+  return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+    query := req.URL.Query()
+    if query.Get("why") == "" {
+      serveStatus(res, req, r.Status())
+      return
+    }
+
+    cc := r.Compiler(query.Get("config"))
+    if cc == nil {
+      http.Error(res, "Configuration not found: " + query.Get("config"),
+                 http.StatusBadRequest)
+      return
+    }
+    serveRequirePaths(res, req, cc)
+  })
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "encoding/json"
+  "errors"
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/soheilhy/glosure/depgraph"
+)
+
+func TestStatus(t *testing.T) {
+  cc := NewCompilerWithSources(newMapSources())
+  cc.UseClosureApi = true
+
+  err := cc.recordCompile("/lib/pkg1.min.js", "api", time.Now(),
+                          []Diagnostic{{Severity: SeverityError,
+                                        Message: "broken"}},
+                          errors.New("Compilation error."))
+  if _, ok := err.(*CompilationError); !ok {
+    t.Error("Error is not a compilation error: ", err)
+  }
+
+  cc.stats.recordHit()
+  cc.stats.recordMiss()
+
+  status := cc.Status()
+  if len(status.Packages) != 3 || status.Packages[0].Namespace != "pkg1" {
+    t.Error("Invalid packages: ", status.Packages)
+  }
+
+  if len(status.EntryPoints) != 1 || status.EntryPoints[0] != "pkg1" {
+    t.Error("Invalid entry points: ", status.EntryPoints)
+  }
+
+  if len(status.Targets) != 1 ||
+     status.Targets[0].Target != "lib/pkg1.min.js" ||
+     status.Targets[0].Error == "" {
+    t.Error("Invalid targets: ", status.Targets)
+  }
+
+  if len(status.Diagnostics) != 1 || status.OutputCache.HitRate != 0.5 {
+    t.Error("Invalid status: ", status)
+  }
+
+  if status.Backend != "api" {
+    t.Error("Invalid backend: ", status.Backend)
+  }
+}
+
+func TestStatusServer(t *testing.T) {
+  cc := NewCompilerWithSources(newMapSources())
+  handler := StatusServer(cc)
+
+  req := httptest.NewRequest("GET", "/status?format=json", nil)
+  res := httptest.NewRecorder()
+  handler.ServeHTTP(res, req)
+
+  statuses := []Status{}
+  err := json.Unmarshal(res.Body.Bytes(), &statuses)
+  if err != nil || len(statuses) != 1 || len(statuses[0].Packages) != 3 {
+    t.Error("Invalid JSON status: ", res.Body.String(), err)
+  }
+
+  req = httptest.NewRequest("GET", "/status", nil)
+  res = httptest.NewRecorder()
+  handler.ServeHTTP(res, req)
+  if !strings.Contains(res.Body.String(), "<td>lib/pkg2.js</td>") {
+    t.Error("Invalid HTML status: ", res.Body.String())
+  }
+}
+
+func TestStatusServerRequirePaths(t *testing.T) {
+  cc := NewCompilerWithSources(newMapSources())
+  handler := StatusServer(cc)
+
+  req := httptest.NewRequest("GET", "/status?entry=pkg1&why=pkg3&all=true",
+                             nil)
+  res := httptest.NewRecorder()
+  handler.ServeHTTP(res, req)
+
+  paths := []depgraph.RequirePath{}
+  err := json.Unmarshal(res.Body.Bytes(), &paths)
+  if err != nil || len(paths) != 2 || len(paths[1].Steps) != 3 ||
+     paths[1].Steps[1].Line != 3 {
+    t.Error("Invalid require paths: ", res.Body.String(), err)
+  }
+
+  req = httptest.NewRequest("GET", "/status?entry=pkg1&why=missing", nil)
+  res = httptest.NewRecorder()
+  handler.ServeHTTP(res, req)
+  if res.Code != http.StatusBadRequest {
+    t.Error("Paths to a missing namespace are served: ", res.Code)
+  }
+}
+
+func TestRegistryStatus(t *testing.T) {
+  r := newTestRegistry(t)
+  statuses := r.Status()
+  if len(statuses) != 2 || statuses[0].Name != "public" ||
+     statuses[1].Name != "admin" {
+    t.Error("Invalid statuses: ", statuses)
+  }
+}
+
+func TestRegistryStatusServerRequirePaths(t *testing.T) {
+  handler := newTestRegistry(t).StatusServer()
+  req := httptest.NewRequest("GET",
+                             "/status?config=admin&entry=pkg2&why=pkg3", nil)
+  res := httptest.NewRecorder()
+  handler.ServeHTTP(res, req)
+  if !strings.Contains(res.Body.String(), `"namespace": "pkg3"`) {
+    t.Error("Invalid require paths: ", res.Body.String())
+  }
+
+  req = httptest.NewRequest("GET", "/status?entry=pkg2&why=pkg3", nil)
+  res = httptest.NewRecorder()
+  handler.ServeHTTP(res, req)
+  if res.Code != http.StatusBadRequest {
+    t.Error("Paths are served without a configuration: ", res.Code)
+  }
+}
@@ -0,0 +1,311 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "crypto/sha256"
+  "encoding/hex"
+  "encoding/json"
+  "io/ioutil"
+  "os"
+  "path"
+  "path/filepath"
+  "strings"
+  "sync"
+  "time"
+)
+
+// OutputStore stores compiled outputs, their encoded variants, CSS renaming
+// maps and the manifest. Names are slash-separated paths relative to the store
+// (e.g., "js/app.min.js").
+type OutputStore interface {
+  // Returns the file info of an output. The error satisfies os.IsNotExist if
+  // the output does not exist.
+  Stat(name string) (os.FileInfo, error)
+  // Returns the content of an output.
+  ReadFile(name string) ([]byte, error)
+  // Atomically replaces the content of an output.
+  WriteFile(name string, content []byte) error
+}
+
+func cleanName(name string) string {
+  return strings.TrimPrefix(path.Clean("/" + name), "/")
+}
+
+// Writes a file atomically by writing into a temporary file in the same
+// directory and renaming it. An existing file keeps its permissions; new files
+// are created with 0644.
+func writeFileAtomically(filePath string, content []byte) error {
+  dir := filepath.Dir(filePath)
+  err := os.MkdirAll(dir, 0755)
+  if err != nil {
+    return err
+  }
+
+  mode := os.FileMode(0644)
+  if stat, err := os.Stat(filePath); err == nil {
+    mode = stat.Mode().Perm()
+  }
+
+  tmp, err := ioutil.TempFile(dir, "." + filepath.Base(filePath) + ".tmp")
+  if err != nil {
+    return err
+  }
+
+  _, err = tmp.Write(content)
+  if err == nil {
+    err = tmp.Chmod(mode)
+  }
+
+  closeErr := tmp.Close()
+  if err == nil {
+    err = closeErr
+  }
+
+  if err == nil {
+    err = os.Rename(tmp.Name(), filePath)
+  }
+
+  if err != nil {
+    os.Remove(tmp.Name())
+  }
+  return err
+}
+
+// DirStore stores outputs in a directory, which can be separate from the
+// sources.
+type DirStore struct {
+  Dir string
+}
+
+// Creates an output store writing into dir.
+func NewDirStore(dir string) *DirStore {
+  return &DirStore{Dir: dir}
+}
+
+func (s *DirStore) path(name string) string {
+  return filepath.Join(s.Dir, filepath.FromSlash(cleanName(name)))
+}
+
+func (s *DirStore) Stat(name string) (os.FileInfo, error) {
+  return os.Stat(s.path(name))
+}
+
+func (s *DirStore) ReadFile(name string) ([]byte, error) {
+  return ioutil.ReadFile(s.path(name))
+}
+
+func (s *DirStore) WriteFile(name string, content []byte) error {
+  return writeFileAtomically(s.path(name), content)
+}
+
+// File info of an output in MemoryStore or ContentAddressedStore.
+type outputInfo struct {
+  name string
+  size int64
+  modTime time.Time
+}
+
+func (i outputInfo) Name() string {
+  return path.Base(i.name)
+}
+
+func (i outputInfo) Size() int64 {
+  return i.size
+}
+
+func (i outputInfo) Mode() os.FileMode {
+  return 0444
+}
+
+func (i outputInfo) ModTime() time.Time {
+  return i.modTime
+}
+
+func (i outputInfo) IsDir() bool {
+  return false
+}
+
+func (i outputInfo) Sys() interface{} {
+  return nil
+}
+
+func notExist(op string, name string) error {
+  return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+}
+
+// MemoryStore keeps outputs in memory. Useful for read-only deployments and
+// tests.
+type MemoryStore struct {
+  files map[string]memoryFile
+  mutex sync.RWMutex
+}
+
+type memoryFile struct {
+  content []byte
+  modTime time.Time
+}
+
+// Creates an empty in-memory output store.
+func NewMemoryStore() *MemoryStore {
+  return &MemoryStore{files: make(map[string]memoryFile)}
+}
+
+func (s *MemoryStore) Stat(name string) (os.FileInfo, error) {
+  name = cleanName(name)
+  s.mutex.RLock()
+  defer s.mutex.RUnlock()
+
+  f, ok := s.files[name]
+  if !ok {
+    return nil, notExist("stat", name)
+  }
+  return outputInfo{name, int64(len(f.content)), f.modTime}, nil
+}
+
+func (s *MemoryStore) ReadFile(name string) ([]byte, error) {
+  name = cleanName(name)
+  s.mutex.RLock()
+  defer s.mutex.RUnlock()
+
+  f, ok := s.files[name]
+  if !ok {
+    return nil, notExist("read", name)
+  }
+  return f.content, nil
+}
+
+func (s *MemoryStore) WriteFile(name string, content []byte) error {
+  c := make([]byte, len(content))
+  copy(c, content)
+
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+  s.files[cleanName(name)] = memoryFile{c, time.Now()}
+  return nil
+}
+
+// ContentAddressedStore stores each distinct output content once under its
+// SHA-256 in "objects/", and keeps an index from output names to hashes in
+// "index.json". Old contents are kept, so outputs can be rolled back.
+type ContentAddressedStore struct {
+  Dir string
+
+  index map[string]contentIndexEntry
+  mutex sync.RWMutex
+}
+
+type contentIndexEntry struct {
+  Hash string `json:"hash"`
+  Size int64 `json:"size"`
+  ModTime time.Time `json:"modTime"`
+}
+
+const contentIndexName = "index.json"
+
+// Creates a content addressed store in dir, and loads its index if any.
+func NewContentAddressedStore(dir string) (*ContentAddressedStore, error) {
+  s := &ContentAddressedStore{
+    Dir: dir,
+    index: make(map[string]contentIndexEntry),
+  }
+
+  content, err := ioutil.ReadFile(filepath.Join(dir, contentIndexName))
+  if os.IsNotExist(err) {
+    return s, nil
+  }
+
+  if err != nil {
+    return nil, err
+  }
+
+  err = json.Unmarshal(content, &s.index)
+  if err != nil {
+    return nil, err
+  }
+  return s, nil
+}
+
+func (s *ContentAddressedStore) objectPath(hash string) string {
+  return filepath.Join(s.Dir, "objects", hash[:2], hash[2:])
+}
+
+func (s *ContentAddressedStore) Stat(name string) (os.FileInfo, error) {
+  name = cleanName(name)
+  s.mutex.RLock()
+  defer s.mutex.RUnlock()
+
+  e, ok := s.index[name]
+  if !ok {
+    return nil, notExist("stat", name)
+  }
+  return outputInfo{name, e.Size, e.ModTime}, nil
+}
+
+func (s *ContentAddressedStore) ReadFile(name string) ([]byte, error) {
+  name = cleanName(name)
+  s.mutex.RLock()
+  e, ok := s.index[name]
+  s.mutex.RUnlock()
+  if !ok {
+    return nil, notExist("read", name)
+  }
+  return ioutil.ReadFile(s.objectPath(e.Hash))
+}
+
+func (s *ContentAddressedStore) WriteFile(name string, content []byte) error {
+  sum := sha256.Sum256(content)
+  hash := hex.EncodeToString(sum[:])
+  objPath := s.objectPath(hash)
+  if _, err := os.Stat(objPath); err != nil {
+    err = writeFileAtomically(objPath, content)
+    if err != nil {
+      return err
+    }
+  }
+
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+
+  s.index[cleanName(name)] = contentIndexEntry{
+    Hash: hash,
+    Size: int64(len(content)),
+    ModTime: time.Now(),
+  }
+
+  index, err := json.MarshalIndent(s.index, "", "  ")
+  if err != nil {
+    return err
+  }
+  return writeFileAtomically(filepath.Join(s.Dir, contentIndexName), index)
+}
+
+// Keeps the outputs of a store under a directory of another store.
+type prefixedStore struct {
+  store OutputStore
+  prefix string
+}
+
+func (s prefixedStore) Stat(name string) (os.FileInfo, error) {
+  return s.store.Stat(path.Join(s.prefix, cleanName(name)))
+}
+
+func (s prefixedStore) ReadFile(name string) ([]byte, error) {
+  return s.store.ReadFile(path.Join(s.prefix, cleanName(name)))
+}
+
+func (s prefixedStore) WriteFile(name string, content []byte) error {
+  return s.store.WriteFile(path.Join(s.prefix, cleanName(name)), content)
+}
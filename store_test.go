@@ -0,0 +1,103 @@
+// Copyright (c) 2014 The Glosure Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glosure
+
+import (
+  "io/ioutil"
+  "net/http"
+  "net/http/httptest"
+  "os"
+  "path/filepath"
+  "testing"
+)
+
+func testOutputStore(t *testing.T, store OutputStore) {
+  _, err := store.Stat("js/app.min.js")
+  if !os.IsNotExist(err) {
+    t.Error("Missing output exists: ", err)
+  }
+
+  for _, content := range []string{"var a;", "var b;"} {
+    err = store.WriteFile("/js/app.min.js", []byte(content))
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    stat, err := store.Stat("js/app.min.js")
+    if err != nil || stat.Size() != int64(len(content)) {
+      t.Error("Invalid output stat: ", stat, err)
+    }
+
+    read, err := store.ReadFile("js/app.min.js")
+    if err != nil || string(read) != content {
+      t.Error("Invalid output content: ", string(read), err)
+    }
+  }
+}
+
+func TestDirStore(t *testing.T) {
+  dir := t.TempDir()
+  testOutputStore(t, NewDirStore(dir))
+
+  files, _ := ioutil.ReadDir(filepath.Join(dir, "js"))
+  if len(files) != 1 {
+    t.Error("Temporary files are left in the store: ", len(files))
+  }
+}
+
+func TestMemoryStore(t *testing.T) {
+  testOutputStore(t, NewMemoryStore())
+}
+
+func TestContentAddressedStore(t *testing.T) {
+  dir := t.TempDir()
+  store, err := NewContentAddressedStore(dir)
+  if err != nil {
+    t.Fatal(err)
+  }
+  testOutputStore(t, store)
+
+  store.WriteFile("js/other.min.js", []byte("var b;"))
+  objects, _ := filepath.Glob(filepath.Join(dir, "objects", "*", "*"))
+  if len(objects) != 2 {
+    t.Error("Same contents are not stored once: ", objects)
+  }
+
+  store, err = NewContentAddressedStore(dir)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  read, err := store.ReadFile("js/other.min.js")
+  if err != nil || string(read) != "var b;" {
+    t.Error("Cannot reload the index: ", string(read), err)
+  }
+}
+
+func TestServeFromMemoryStore(t *testing.T) {
+  root := newCompiledRoot(t)
+  os.Remove(filepath.Join(root, "app.min.js"))
+
+  cc := NewCompiler(root)
+  cc.Outputs = NewMemoryStore()
+  cc.Outputs.WriteFile("app.min.js", []byte("var app={};\n"))
+
+  res := httptest.NewRecorder()
+  req, _ := http.NewRequest("GET", "/app.min.js", nil)
+  ServeHttp(res, req, &cc)
+  if res.Code != http.StatusOK || res.Body.String() != "var app={};\n" {
+    t.Error("Cannot serve an output from memory: ", res.Code)
+  }
+}